@@ -0,0 +1,402 @@
+package log
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const archiveTimeLayout = "2006-01-02_15-04-05"
+
+// ArchiveNaming decides where rotated logs go and how they are named
+//
+// By default archives sit next to the log as <name>-2006-01-02_15-04-05.gz in UTC,
+// logs rotated within the same second get a .1, .2, ... suffix
+type ArchiveNaming struct {
+	Dir        string         // archive directory, relative to the log's, must be on the same filesystem
+	Sequence   bool           // name archives <name>.1, <name>.2, ... counting up, instead of by time
+	TimeFormat string         // defaults to 2006-01-02_15-04-05
+	Location   *time.Location // timezone of the timestamps, defaults to UTC, time.Local for local time
+}
+
+// WithArchiveNaming changes where rotated logs go and how they are named
+//
+// Retention only considers archives matching the current naming
+func WithArchiveNaming(n ArchiveNaming) FileOption {
+	return func(f *FileHandler) { f.naming = n }
+}
+
+// Compression decides how rotated logs are compressed, defaults to gzip
+type Compression struct {
+	Disabled bool // keep rotated logs as they are
+	Level    int  // gzip level, 0 means gzip.DefaultCompression
+}
+
+// WithCompression changes how rotated logs are compressed,
+// invalid levels fall back to gzip.DefaultCompression
+func WithCompression(c Compression) FileOption {
+	return func(f *FileHandler) {
+		if c.Level < gzip.HuffmanOnly || c.Level > gzip.BestCompression {
+			c.Level = 0
+		}
+		f.compression = c
+	}
+}
+
+type archive struct {
+	path    string
+	created time.Time
+	seq     int // the sequence number, or tells apart archives rotated within the same second
+	size    int64
+	pending bool // rotated but not compressed yet
+	partial bool // the .gz.tmp of a compression in progress or interrupted
+}
+
+// callers responsibility to hold muFile
+func (f *FileHandler) archiveDir() string {
+	switch {
+	case f.naming.Dir == "":
+		return f.logDir
+	case filepath.IsAbs(f.naming.Dir):
+		return f.naming.Dir
+	default:
+		return filepath.Join(f.logDir, f.naming.Dir)
+	}
+}
+
+func (f *FileHandler) archiveTimeFormat() string {
+	if f.naming.TimeFormat == "" {
+		return archiveTimeLayout
+	}
+	return f.naming.TimeFormat
+}
+
+func (f *FileHandler) archiveLocation() *time.Location {
+	if f.naming.Location == nil {
+		return time.UTC
+	}
+	return f.naming.Location
+}
+
+// pendingArchivePath picks a free path for a log rotated at t,
+// the log waits there until it is compressed to the same path + ".gz"
+//
+// callers responsibility to hold muFile
+func (f *FileHandler) pendingArchivePath(t time.Time) (string, error) {
+	dir := f.archiveDir()
+	if err := os.MkdirAll(dir, f.dirMode); err != nil {
+		return "", err
+	}
+
+	if f.naming.Sequence {
+		base := filepath.Join(dir, f.logFilename+".")
+		path := base + strconv.Itoa(f.nextSeq)
+		for exists(path) || exists(path+".gz") { // taken by another process sharing the log
+			f.nextSeq++
+			path = base + strconv.Itoa(f.nextSeq)
+		}
+		f.nextSeq++
+		return path, nil
+	}
+
+	base := filepath.Join(dir, f.logFilename+"-"+t.In(f.archiveLocation()).Format(f.archiveTimeFormat()))
+	path := base
+	for i := 1; exists(path) || exists(path+".gz"); i++ {
+		path = base + "." + strconv.Itoa(i) // rotated more than once in the same second
+	}
+	return path, nil
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// parseArchiveName parses the name of one of the handler's archives,
+// ok is false for files that are not archives of this handler
+//
+// callers responsibility to hold muFile
+func (f *FileHandler) parseArchiveName(name string) (a archive, ok bool) {
+	rest, partial := strings.CutSuffix(name, ".gz.tmp")
+	compressed := false
+	if !partial {
+		rest, compressed = strings.CutSuffix(rest, ".gz")
+	}
+	a.partial = partial
+	a.pending = !partial && !compressed && !f.compression.Disabled
+
+	if f.naming.Sequence {
+		seq, found := strings.CutPrefix(rest, f.logFilename+".")
+		if !found {
+			return archive{}, false
+		}
+		n, err := strconv.ParseUint(seq, 10, 31)
+		if err != nil || n == 0 {
+			return archive{}, false
+		}
+		a.seq = int(n)
+	} else {
+		stamp, found := strings.CutPrefix(rest, f.logFilename+"-")
+		if !found {
+			return archive{}, false
+		}
+		if a.created, a.seq, ok = f.parseArchiveStamp(stamp); !ok {
+			return archive{}, false
+		}
+	}
+
+	a.path = filepath.Join(f.archiveDir(), name)
+	return a, true
+}
+
+// parseArchiveStamp parses the timestamp of an archive with its optional .N suffix
+func (f *FileHandler) parseArchiveStamp(stamp string) (created time.Time, seq int, ok bool) {
+	format, loc := f.archiveTimeFormat(), f.archiveLocation()
+	if created, err := time.ParseInLocation(format, stamp, loc); err == nil {
+		return created, 0, true
+	}
+
+	i := strings.LastIndexByte(stamp, '.')
+	if i < 0 {
+		return time.Time{}, 0, false
+	}
+	n, err := strconv.ParseUint(stamp[i+1:], 10, 31)
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+	created, err = time.ParseInLocation(format, stamp[:i], loc)
+	return created, int(n), err == nil
+}
+
+// archives lists the handler's archives, newest first
+//
+// callers responsibility to hold muFile
+func (f *FileHandler) archives() ([]archive, error) {
+	entries, err := os.ReadDir(f.archiveDir())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil // nothing rotated yet
+		}
+		return nil, err
+	}
+
+	var out []archive
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		a, ok := f.parseArchiveName(e.Name())
+		if !ok {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue // removed in the meantime
+		}
+		a.size = info.Size()
+		if f.naming.Sequence {
+			a.created = info.ModTime()
+		}
+		out = append(out, a)
+	}
+
+	slices.SortFunc(out, func(a, b archive) int {
+		if !f.naming.Sequence {
+			if c := b.created.Compare(a.created); c != 0 {
+				return c
+			}
+		}
+		return b.seq - a.seq
+	})
+	return out, nil
+}
+
+// pendingArchives lists rotated logs that were never compressed,
+// like after a crash mid-compression, and picks up the sequence where it left off.
+// Partial archives left behind without their rotated log are removed
+//
+// callers responsibility to hold muFile
+func (f *FileHandler) pendingArchives() ([]string, error) {
+	f.nextSeq = 1
+	archives, err := f.archives()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(archives) > 0 {
+		f.nextSeq = archives[0].seq + 1
+	}
+
+	var (
+		pending []string
+		errs    []error
+	)
+	for _, a := range archives {
+		switch {
+		case a.pending:
+			pending = append(pending, a.path)
+		case a.partial && !exists(strings.TrimSuffix(a.path, ".gz.tmp")):
+			// otherwise compressing the rotated log again overwrites it
+			if err := os.Remove(a.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return pending, errors.Join(errs...)
+}
+
+// errArchiveTaken is returned for archives another process is finishing
+var errArchiveTaken = errors.New("archive is finished by another process")
+
+type archiveResult struct {
+	path string
+	err  error
+}
+
+// compressInBackground finishes the pending archives and prunes afterwards,
+// closing the handler waits for it. Once closing, archives are left pending for the next start
+//
+// Rotate hooks and diagnostics run on a goroutine of their own that closing does not wait for,
+// they may log through loggers that are closing this handler. With hooks, retention runs after them
+//
+// the returned channel receives a result per pending archive and is closed once done.
+// callers responsibility to hold muFile
+func (f *FileHandler) compressInBackground(pending ...string) <-chan archiveResult {
+	results := make(chan archiveResult, len(pending))
+	if f.closing {
+		for _, path := range pending {
+			results <- archiveResult{path: path, err: ErrNotStarted}
+		}
+		close(results)
+		return results
+	}
+
+	f.compressing.Add(1)
+	go noPanicRunVoid("log-compress", func() {
+		defer f.compressing.Done()
+		defer close(results)
+
+		var (
+			finished []string
+			errs     []error
+		)
+		for _, path := range pending {
+			archive, err := f.finishArchive(path)
+			if err == nil {
+				finished = append(finished, archive)
+			} else if !errors.Is(err, errArchiveTaken) {
+				errs = append(errs, fmt.Errorf("failed to compress rotated log: %w", err))
+			}
+			results <- archiveResult{path: archive, err: err}
+		}
+		if len(f.rotateHooks) == 0 {
+			if err := f.prune(); err != nil {
+				errs = append(errs, fmt.Errorf("failed to prune rotated logs: %w", err))
+			}
+		}
+
+		if len(f.rotateHooks) > 0 || len(errs) > 0 {
+			go noPanicRunVoid("log-rotate-hook", func() { f.afterArchives(finished, errs) })
+		}
+	})
+	return results
+}
+
+// afterArchives reports errs, then runs the rotate hooks on the finished archives
+// and applies retention once they are done
+func (f *FileHandler) afterArchives(finished []string, errs []error) {
+	for _, err := range errs {
+		Error().Msgf("%v", err).Send()
+	}
+	if len(f.rotateHooks) == 0 {
+		return
+	}
+
+	for _, archive := range finished {
+		for _, hook := range f.rotateHooks {
+			_ = noPanicRun("log-rotate-hook", func() error {
+				hook(archive)
+				return nil
+			})
+		}
+	}
+	if err := f.prune(); err != nil {
+		Error().Msgf("failed to prune rotated logs: %v", err).Send()
+	}
+}
+
+// finishArchive compresses a pending archive
+func (f *FileHandler) finishArchive(pending string) (string, error) {
+	if f.compression.Disabled {
+		return pending, nil
+	}
+	if err := f.compress(pending); err != nil {
+		return "", err
+	}
+	return pending + ".gz", nil
+}
+
+// compress gzips path into path + ".gz" as a stream and removes path
+//
+// the archive only appears under its final name once complete,
+// an interrupted compression is retried on the next start
+func (f *FileHandler) compress(path string) error {
+	src, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = src.Close() }()
+
+	if f.processLock {
+		// another process may be finishing it already, like after both started
+		if locked, err := tryLockFile(src); err != nil || !locked || !exists(path) {
+			return errors.Join(errArchiveTaken, err)
+		}
+	}
+
+	level := f.compression.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+
+	dst := path + ".gz"
+	tmp := dst + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, f.fileMode)
+	if err != nil {
+		return err
+	}
+
+	gz, err := gzip.NewWriterLevel(out, level)
+	if err == nil {
+		_, err = io.Copy(gz, src)
+	}
+	if err == nil {
+		err = gz.Close()
+	}
+	if err == nil {
+		err = out.Sync()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, dst)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	if f.processLock {
+		return os.Remove(path) // before unlocking, so nobody compresses it twice
+	}
+	_ = src.Close()
+	return os.Remove(path)
+}
@@ -0,0 +1,147 @@
+package log
+
+import "time"
+
+// BackpressureMode decides what happens to a message
+// when a handler's queue is full
+type BackpressureMode int
+
+const (
+	DropNewest     BackpressureMode = iota // drop the incoming message (default)
+	DropOldest                             // drop the oldest queued message to make room
+	Block                                  // wait for room, never drop while running
+	BlockTimeout                           // wait up to Timeout, then drop the incoming message
+	DropBelowLevel                         // wait for messages at or above Level, drop the rest
+)
+
+// Backpressure is the policy of a handler's queue
+type Backpressure struct {
+	Mode    BackpressureMode
+	Timeout time.Duration // used by BlockTimeout
+	Level   Level         // used by DropBelowLevel, defaults to ERROR
+}
+
+const defaultQueueSize = 1 << 10
+
+// SetBackpressure changes what happens when the queue is full
+func (b *BaseHandler) SetBackpressure(bp Backpressure) {
+	if bp.Mode == DropBelowLevel && bp.Level == TRACE {
+		bp.Level = ERROR
+	}
+
+	b.mu.Lock()
+	b.backpressure = bp
+	b.mu.Unlock()
+}
+
+func (b *BaseHandler) GetBackpressure() Backpressure {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.backpressure
+}
+
+// SetQueueSize sets the capacity of the queue, defaults to 1024
+//
+// Must be called before the handler is started
+func (b *BaseHandler) SetQueueSize(size int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.running {
+		return ErrAlreadyStarted
+	}
+	if size < 0 {
+		return ErrInvalidQueueSize
+	}
+	b.queueSize = size
+	return nil
+}
+
+func (b *BaseHandler) GetQueueSize() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.queueSize == 0 {
+		return defaultQueueSize
+	}
+	return b.queueSize
+}
+
+// Dropped returns the number of messages dropped because the queue was full,
+// including those still waiting for room when the handler closed
+func (b *BaseHandler) Dropped() uint64 { return b.dropped.Load() }
+
+// enqueue applies the backpressure policy, m is released if dropped
+func (b *BaseHandler) enqueue(bp Backpressure, done <-chan struct{}, logCh chan *LogMessage, m *LogMessage) {
+	select {
+	case logCh <- m:
+		return
+	case <-done:
+		b.dropped.Add(1)
+		releaseLogMessage(m)
+		return
+	default:
+	}
+
+	switch bp.Mode {
+	case Block:
+		b.enqueueBlocking(done, nil, logCh, m)
+		return
+
+	case BlockTimeout:
+		timer := time.NewTimer(bp.Timeout)
+		defer timer.Stop()
+		b.enqueueBlocking(done, timer.C, logCh, m)
+		return
+
+	case DropBelowLevel:
+		if m.Level >= bp.Level {
+			b.enqueueBlocking(done, nil, logCh, m)
+			return
+		}
+
+	case DropOldest:
+		for range 3 { // give up if other senders keep taking the room
+			b.dropOldest(logCh)
+
+			select {
+			case logCh <- m:
+				return
+			default:
+			}
+		}
+	}
+
+	b.dropped.Add(1)
+	releaseLogMessage(m)
+}
+
+// dropOldest makes room by dropping the oldest queued message,
+// a flush marker is requeued instead and fails if other senders took its room
+func (b *BaseHandler) dropOldest(logCh chan *LogMessage) {
+	select {
+	case old := <-logCh:
+		if old.flushed == nil {
+			b.dropped.Add(1)
+			releaseLogMessage(old)
+			return
+		}
+		select {
+		case logCh <- old:
+		default:
+			old.flushed <- ErrFlushDropped
+		}
+	default:
+	}
+}
+
+func (b *BaseHandler) enqueueBlocking(done <-chan struct{}, timeout <-chan time.Time, logCh chan *LogMessage, m *LogMessage) {
+	select {
+	case logCh <- m:
+	case <-done:
+		b.dropped.Add(1)
+		releaseLogMessage(m)
+	case <-timeout:
+		b.dropped.Add(1)
+		releaseLogMessage(m)
+	}
+}
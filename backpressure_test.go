@@ -0,0 +1,169 @@
+package log_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lattesec/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// handler that blocks on its first message until released
+//
+// Handle("first") returns once the handler holds it, so the queue starts empty
+func newGatedHandler(t *testing.T, queueSize int, bp log.Backpressure) (h *log.BaseHandler, release func(), got func() []string) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var msgs []string
+	gate := make(chan struct{})
+	picked := make(chan struct{}, 1)
+
+	h = &log.BaseHandler{
+		HandleFunc: func(_ context.Context, msg *log.LogMessage) error {
+			select {
+			case picked <- struct{}{}:
+			default:
+			}
+			<-gate
+			mu.Lock()
+			msgs = append(msgs, msg.Message)
+			mu.Unlock()
+			return nil
+		},
+	}
+	require.NoError(t, h.SetQueueSize(queueSize))
+	h.SetBackpressure(bp)
+	require.NoError(t, h.Start())
+
+	h.Handle("", &log.LogMessage{Level: log.FATAL, Message: "first"})
+	<-picked
+
+	var once sync.Once
+	return h, func() { once.Do(func() { close(gate) }) }, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), msgs...)
+	}
+}
+
+func TestBackpressureDropNewest(t *testing.T) {
+	h, release, got := newGatedHandler(t, 1, log.Backpressure{})
+
+	for _, m := range []string{"a", "b", "c"} {
+		h.Handle("", &log.LogMessage{Message: m})
+	}
+	release()
+	require.NoError(t, h.Close())
+
+	assert.Equal(t, []string{"first", "a"}, got())
+	assert.Equal(t, uint64(2), h.Dropped())
+}
+
+func TestBackpressureDropOldest(t *testing.T) {
+	h, release, got := newGatedHandler(t, 1, log.Backpressure{Mode: log.DropOldest})
+
+	for _, m := range []string{"a", "b", "c"} {
+		h.Handle("", &log.LogMessage{Message: m})
+	}
+	release()
+	require.NoError(t, h.Close())
+
+	assert.Equal(t, []string{"first", "c"}, got())
+	assert.Equal(t, uint64(2), h.Dropped())
+}
+
+func TestBackpressureBlock(t *testing.T) {
+	h, release, got := newGatedHandler(t, 1, log.Backpressure{Mode: log.Block})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, m := range []string{"a", "b", "c", "d"} {
+			h.Handle("", &log.LogMessage{Message: m})
+		}
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("expected Handle to block while the queue is full")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	release()
+	<-done
+	require.NoError(t, h.Close())
+
+	assert.Equal(t, []string{"first", "a", "b", "c", "d"}, got())
+	assert.Zero(t, h.Dropped())
+}
+
+func TestBackpressureBlockTimeout(t *testing.T) {
+	h, release, got := newGatedHandler(t, 1, log.Backpressure{Mode: log.BlockTimeout, Timeout: 5 * time.Millisecond})
+
+	for _, m := range []string{"a", "b"} {
+		h.Handle("", &log.LogMessage{Message: m})
+	}
+	release()
+	require.NoError(t, h.Close())
+
+	assert.Equal(t, []string{"first", "a"}, got())
+	assert.Equal(t, uint64(1), h.Dropped())
+}
+
+func TestBackpressureDropBelowLevel(t *testing.T) {
+	h, release, got := newGatedHandler(t, 1, log.Backpressure{Mode: log.DropBelowLevel, Level: log.ERROR})
+
+	h.Handle("", &log.LogMessage{Level: log.INFO, Message: "a"})
+	h.Handle("", &log.LogMessage{Level: log.INFO, Message: "dropped"})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.Handle("", &log.LogMessage{Level: log.ERROR, Message: "kept"})
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("expected ERROR to wait for room")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	release()
+	<-done
+	require.NoError(t, h.Close())
+
+	assert.Equal(t, []string{"first", "a", "kept"}, got())
+	assert.Equal(t, uint64(1), h.Dropped())
+}
+
+func TestBackpressureDropBelowLevelDefaultsToError(t *testing.T) {
+	h, release, got := newGatedHandler(t, 1, log.Backpressure{Mode: log.DropBelowLevel})
+
+	h.Handle("", &log.LogMessage{Level: log.INFO, Message: "a"})
+	h.Handle("", &log.LogMessage{Level: log.WARN, Message: "dropped"})
+	release()
+	require.NoError(t, h.Close())
+
+	assert.Equal(t, []string{"first", "a"}, got())
+	assert.Equal(t, uint64(1), h.Dropped())
+}
+
+func TestBackpressureDropOldestKeepsFlushMarkers(t *testing.T) {
+	h, release, got := newGatedHandler(t, 1, log.Backpressure{Mode: log.DropOldest})
+
+	flushed := make(chan error, 1)
+	go func() { flushed <- h.Flush(context.Background()) }()
+	require.Eventually(t, func() bool {
+		h.Handle("", &log.LogMessage{Message: "a"}) // returns once the marker is queued and a was dropped
+		return h.Dropped() > 0
+	}, time.Second, time.Millisecond)
+
+	release()
+	require.NoError(t, <-flushed)
+	require.NoError(t, h.Close())
+	assert.Equal(t, "first", got()[0])
+}
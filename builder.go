@@ -1,12 +1,22 @@
 package log
 
-import "io"
+import (
+	"errors"
+	"io"
+)
 
 type (
 	LoggerBuilder struct {
 		LoggerMeta
 		path           string
-		maxLogFileSize int64 // set to 0 to disable rotations
+		maxLogFileSize int64 // set to 0 or -1 to disable size-based rotations
+		fileOpts       []FileOption
+
+		// applied to handlers the builder creates
+		formatter    Formatter
+		queueSize    int
+		backpressure *Backpressure
+		created      []builtHandler
 	}
 
 	LoggerMeta struct {
@@ -17,10 +27,17 @@ type (
 		stderrEnabled bool
 
 		handlers []LogHandler
-		cleanup  []func() // to be ran on fatal
+		cleanup  []func() // registered with CleanupPriorityUser while running, ran on Shutdown, Close and Fatal
 	}
 )
 
+// handlers created by the builder
+type builtHandler interface {
+	SetFormatter(Formatter)
+	SetBackpressure(Backpressure)
+	SetQueueSize(int) error
+}
+
 func NewLogger() *LoggerBuilder {
 	return &LoggerBuilder{
 		LoggerMeta: LoggerMeta{
@@ -37,10 +54,18 @@ func (lb *LoggerBuilder) Build() (*Logger, error) {
 	}
 
 	if lb.path != "" {
-		fh, err := NewFileHandler(lb.path)
+		created := newFileHandler(lb.path, lb.fileOpts...)
+		if err := lb.configure(created); err != nil {
+			return nil, err
+		}
+		fh, err := newRefCounted(created)
 		if err != nil {
 			return nil, err
 		}
+		// a handler shared with another logger keeps its settings
+		if fh != created && lb.formatter != nil && !sameFormatter(fh.GetFormatter(), lb.formatter) {
+			return nil, errors.Join(ErrFormatterConflict, fh.Close())
+		}
 
 		switch lb.maxLogFileSize {
 		case 0:
@@ -58,13 +83,28 @@ func (lb *LoggerBuilder) Build() (*Logger, error) {
 		lb.path = ""
 	}
 
+	for _, h := range lb.created {
+		if err := lb.configure(h); err != nil && !errors.Is(err, ErrAlreadyStarted) {
+			return nil, err
+		}
+	}
+
 	if lb.name == "" {
 		lb.name = "???"
 	}
 
-	return &Logger{
-		LoggerMeta: lb.LoggerMeta,
-	}, nil
+	return newLogger(&loggerCore{LoggerMeta: lb.LoggerMeta}), nil
+}
+
+// configure applies the builder's settings to a handler it created
+func (lb *LoggerBuilder) configure(h builtHandler) error {
+	if lb.formatter != nil {
+		h.SetFormatter(lb.formatter)
+	}
+	if lb.backpressure != nil {
+		h.SetBackpressure(*lb.backpressure)
+	}
+	return h.SetQueueSize(lb.queueSize)
 }
 
 func (lb *LoggerBuilder) WithHandlers(hs ...LogHandler) *LoggerBuilder {
@@ -72,23 +112,56 @@ func (lb *LoggerBuilder) WithHandlers(hs ...LogHandler) *LoggerBuilder {
 	return lb
 }
 
+// WithCleanup adds functions to the cleanup registry while the logger runs,
+// they run before any logger is closed by Shutdown, Close or Fatal, see Shutdown.
+// Closing only this logger removes them without running them
 func (lb *LoggerBuilder) WithCleanup(fns ...func()) *LoggerBuilder {
 	lb.cleanup = append(lb.cleanup, fns...)
 	return lb
 }
 
+// WithQueueSize sets the queue capacity of the handlers created by the builder
+//
+// A file handler shared with another logger keeps the size it was started with
+func (lb *LoggerBuilder) WithQueueSize(size int) *LoggerBuilder {
+	lb.queueSize = size
+	return lb
+}
+
+// WithBackpressure sets what the handlers created by the builder do when their queue is full
+//
+// A file handler shared with another logger keeps its own
+func (lb *LoggerBuilder) WithBackpressure(bp Backpressure) *LoggerBuilder {
+	lb.backpressure = &bp
+	return lb
+}
+
 func (lb *LoggerBuilder) Name(name string) *LoggerBuilder { lb.name = name; return lb }
 
 // path is the path to the log file
-// maxLogFileSize is the maximum size of the log file in bytes before it is rotated (set to -1 to disable rotations)
-func (lb *LoggerBuilder) WithFile(path string, maxLogFileSize int64) *LoggerBuilder {
+// maxLogFileSize is the maximum size of the log file in bytes before it is rotated (set to 0 or -1 to disable size-based rotations)
+// opts configure the file handler, like WithSchedule for time-based rotations
+func (lb *LoggerBuilder) WithFile(path string, maxLogFileSize int64, opts ...FileOption) *LoggerBuilder {
 	lb.path = path
 	lb.maxLogFileSize = maxLogFileSize
+	lb.fileOpts = opts
 	return lb
 }
 
 func (lb *LoggerBuilder) WithWriter(wr io.Writer) *LoggerBuilder {
-	lb.handlers = append(lb.handlers, NewWriterHandler(wr))
+	h := NewWriterHandler(wr)
+	lb.handlers = append(lb.handlers, h)
+	lb.created = append(lb.created, h)
+	return lb
+}
+
+// WithFormatter sets the Formatter of the handlers created
+// by the builder (WithFile and WithWriter)
+//
+// Handlers passed to WithHandlers keep their own Formatter,
+// Build fails with ErrFormatterConflict if a file handler shared with another logger uses a different one
+func (lb *LoggerBuilder) WithFormatter(f Formatter) *LoggerBuilder {
+	lb.formatter = f
 	return lb
 }
 
@@ -1,62 +1,125 @@
 package log
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"os"
-	"os/signal"
+	"slices"
 	"sync"
-	"sync/atomic"
-	"syscall"
+	"time"
 )
 
-type cleanupFunc func() error
+// Cleanup priorities, lower priorities run first
+// and entries sharing a priority run in registration order
+const (
+	CleanupPriorityUser    = 0   // WithCleanup and most application cleanups, logging still works
+	CleanupPriorityLogger  = 100 // closing loggers, which closes the handlers they own
+	CleanupPriorityHandler = 200 // handlers that no logger closed
+	CleanupPriorityStd     = 300 // the default stdout and stderr handlers
+)
 
-var (
-	once sync.Once
+// how long a single cleanup may take unless its Timeout says otherwise
+const defaultCleanupTimeout = 5 * time.Second
+
+// Cleanup is an entry of the cleanup registry ran by Shutdown
+type Cleanup struct {
+	Name     string                          // used when reporting failures
+	Priority int                             // see CleanupPriorityUser and friends
+	Timeout  time.Duration                   // defaults to 5s, Shutdown's ctx may cut it shorter
+	Fn       func(ctx context.Context) error // should return once ctx is done
+}
 
+type cleanupEntry struct {
+	Cleanup
+	id uint64
+}
+
+var (
 	muCleanup    sync.Mutex
 	cleanupIdGen uint64
-	cleanupFns   = make(map[uint64]cleanupFunc)
+	cleanups     []cleanupEntry // in registration order
 )
 
-// Register registers a cleanup function
-// that is called on exit
-func registerCleanup(fn cleanupFunc) uint64 {
-	id := atomic.AddUint64(&cleanupIdGen, 1)
+// RegisterCleanup adds c to the registry,
+// the returned function removes it again
+func RegisterCleanup(c Cleanup) (unregister func()) {
+	id := registerCleanup(c)
+	return func() { unregisterCleanup(id) }
+}
+
+func registerCleanup(c Cleanup) uint64 {
 	muCleanup.Lock()
-	cleanupFns[id] = fn
-	muCleanup.Unlock()
-	return id
+	defer muCleanup.Unlock()
+
+	cleanupIdGen++
+	cleanups = append(cleanups, cleanupEntry{Cleanup: c, id: cleanupIdGen})
+	return cleanupIdGen
 }
 
 func unregisterCleanup(id uint64) {
 	muCleanup.Lock()
-	delete(cleanupFns, id)
+	cleanups = slices.DeleteFunc(cleanups, func(e cleanupEntry) bool { return e.id == id })
 	muCleanup.Unlock()
 }
 
-func runCleanup() {
+// changes the name and priority of a registered cleanup
+func updateCleanup(id uint64, name string, priority int) {
+	muCleanup.Lock()
+	defer muCleanup.Unlock()
+
+	for i := range cleanups {
+		if cleanups[i].id == id {
+			cleanups[i].Name = name
+			cleanups[i].Priority = priority
+			return
+		}
+	}
+}
+
+// takeNextCleanup unregisters and returns the cleanup that runs next
+//
+// Taking them one by one lets a cleanup unregister the ones it made redundant,
+// like a logger closing its handlers
+func takeNextCleanup() (Cleanup, bool) {
 	muCleanup.Lock()
-	fns := make([]cleanupFunc, 0, len(cleanupFns))
-	for _, fn := range cleanupFns {
-		fns = append(fns, fn)
+	defer muCleanup.Unlock()
+
+	if len(cleanups) == 0 {
+		return Cleanup{}, false
 	}
-	cleanupFns = make(map[uint64]cleanupFunc)
-	atomic.StoreUint64(&cleanupIdGen, 0)
-	muCleanup.Unlock()
-	for i, fn := range fns {
-		name := fmt.Sprintf("cleanup %d", i)
-		if err := noPanicRun(name, fn); err != nil {
-			fmt.Fprintf(os.Stderr, "%s failed: %v\n", name, err)
+
+	i := 0
+	for j, e := range cleanups {
+		if e.Priority < cleanups[i].Priority {
+			i = j
 		}
 	}
+
+	c := cleanups[i].Cleanup
+	cleanups = slices.Delete(cleanups, i, i+1)
+	return c, true
 }
 
-func handleSigint() {
-	once.Do(func() {
-		sigs := make(chan os.Signal, 1)
-		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
-		<-sigs
-		runCleanup()
-	})
+// run waits for the cleanup until its timeout or ctx is done,
+// an unfinished cleanup keeps running in the background
+func (c Cleanup) run(ctx context.Context) error {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = defaultCleanupTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- noPanicRun(c.Name, func() error { return c.Fn(ctx) }) }()
+
+	select {
+	case err := <-done:
+		if err != nil && !errors.Is(err, ErrNotStarted) {
+			return fmt.Errorf("%s: %w", c.Name, err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%s did not finish in time: %w", c.Name, ctx.Err())
+	}
 }
@@ -0,0 +1,6 @@
+//go:build !(linux || darwin || freebsd)
+
+package log
+
+// diskFree is unsupported, a DiskGuard needs its own Probe
+var diskFree func(dir string) (uint64, error)
@@ -0,0 +1,13 @@
+//go:build unix
+
+package log
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isDiskFull reports whether err is a write running out of space
+func isDiskFull(err error) bool {
+	return errors.Is(err, syscall.ENOSPC)
+}
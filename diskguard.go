@@ -0,0 +1,195 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DiskGuardAction is what a FileHandler does while free disk space is low
+type DiskGuardAction int
+
+const (
+	DiskDropBelowLevel DiskGuardAction = iota // drop messages below Level (default)
+	DiskPrune                                 // remove archives, oldest first, until enough space is free
+	DiskFallback                              // hand messages to Fallback instead of the file
+)
+
+// DiskGuard watches the free space of the log's filesystem
+type DiskGuard struct {
+	MinFree  uint64          // bytes that must stay free, defaults to 16MiB
+	Action   DiskGuardAction // what to do while less is free
+	Level    Level           // used by DiskDropBelowLevel, defaults to ERROR
+	Fallback LogHandler      // used by DiskFallback, must be started by the caller
+	Interval time.Duration   // how often free space is checked, defaults to 10s
+
+	// Probe returns the free bytes for the directory of the log,
+	// defaults to the space available on its filesystem. Required outside of linux, darwin and freebsd
+	Probe func(dir string) (uint64, error)
+}
+
+const (
+	defaultDiskGuardMinFree  = 16 << 20
+	defaultDiskGuardInterval = 10 * time.Second
+)
+
+// WithDiskGuard checks free disk space periodically and whenever a write runs out of it
+//
+// A diagnostic is logged when the guard trips and another when it clears,
+// write errors caused by a full disk are not reported in between.
+// Starting the handler fails with ErrDiskGuardUnsupported without a Probe for the platform
+func WithDiskGuard(g DiskGuard) FileOption {
+	return func(f *FileHandler) {
+		if g.MinFree == 0 {
+			g.MinFree = defaultDiskGuardMinFree
+		}
+		if g.Level == TRACE {
+			g.Level = ERROR
+		}
+		if g.Interval <= 0 {
+			g.Interval = defaultDiskGuardInterval
+		}
+		if g.Probe == nil {
+			g.Probe = diskFree // nil where unsupported
+		}
+		f.diskGuard = &g
+		f.diskCheck = make(chan struct{}, 1)
+	}
+}
+
+// guardDisk checks free space on every tick and whenever a write asks for it
+func (f *FileHandler) guardDisk(ctx context.Context) error {
+	g := f.diskGuard
+	if g == nil {
+		return nil
+	}
+
+	ticker := time.NewTicker(g.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := f.checkDisk(); err != nil {
+			Error().Msgf("failed to check free disk space: %v", err).Send()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		case <-f.diskCheck:
+		}
+	}
+}
+
+// checkDisk updates whether space is low, it is the only one logging about it
+// so diagnostics are never written while muFile is held
+func (f *FileHandler) checkDisk() error {
+	g := f.diskGuard
+
+	f.muFile.Lock()
+	dir, base := f.getLogfileLocation()
+	f.muFile.Unlock()
+
+	free, err := g.Probe(dir)
+	if err != nil {
+		return err
+	}
+
+	if free < g.MinFree && g.Action == DiskPrune {
+		if err := f.pruneForSpace(func() bool {
+			free, err = g.Probe(dir)
+			return err == nil && free >= g.MinFree
+		}); err != nil {
+			Error().Msgf("failed to prune rotated logs: %v", err).Send()
+		}
+	}
+	// a write that ran out of space keeps the guard tripped until the next check
+	low := f.diskFullWrite.Swap(false) || free < g.MinFree
+	f.lowDisk.Store(low)
+
+	if low == f.diskReported {
+		return nil
+	}
+	f.diskReported = low
+	path := filepath.Join(dir, base)
+	if low {
+		Warn().Msgf("log %s: low disk space, %d bytes free, need %d", path, free, g.MinFree).Send()
+	} else {
+		Warn().Msgf("log %s: disk space recovered, %d bytes free", path, free).Send()
+	}
+	return nil
+}
+
+// guarded reports whether msg was taken care of by the disk guard,
+// like dropping it, instead of being written
+//
+// callers responsibility to hold muFile
+func (f *FileHandler) guarded(msg *LogMessage) bool {
+	if f.diskGuard == nil || !f.lowDisk.Load() {
+		return false
+	}
+
+	switch f.diskGuard.Action {
+	case DiskDropBelowLevel:
+		if msg.Level < f.diskGuard.Level {
+			f.dropped.Add(1)
+			return true
+		}
+	case DiskFallback:
+		if f.diskGuard.Fallback != nil {
+			f.diskGuard.Fallback.Handle(msg.LoggerName(), msg)
+			return true
+		}
+	}
+	return false
+}
+
+// diskFull trips the guard once a write ran out of space,
+// reporting whether the error should be kept quiet
+//
+// callers responsibility to hold muFile
+func (f *FileHandler) diskFull(msg *LogMessage, err error) bool {
+	if f.diskGuard == nil || !isDiskFull(err) {
+		return false
+	}
+
+	f.lowDisk.Store(true)
+	f.diskFullWrite.Store(true)
+	select {
+	case f.diskCheck <- struct{}{}: // the guard reports it, and clears it again once space is free
+	default:
+	}
+
+	if !f.guarded(msg) {
+		f.dropped.Add(1)
+	}
+	return true
+}
+
+// pruneForSpace applies the retention policy, then removes archives
+// oldest first until enough is free
+func (f *FileHandler) pruneForSpace(enough func() bool) error {
+	if err := f.prune(); err != nil {
+		return err
+	}
+
+	f.muFile.Lock()
+	archives, err := f.archives()
+	f.muFile.Unlock()
+	if err != nil {
+		return err
+	}
+
+	for i := len(archives) - 1; i >= 0 && !enough(); i-- {
+		if archives[i].pending || archives[i].partial {
+			continue // still being compressed
+		}
+		if err := os.Remove(archives[i].path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove %s: %w", archives[i].path, err)
+		}
+	}
+	return nil
+}
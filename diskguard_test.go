@@ -0,0 +1,138 @@
+package log_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lattesec/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestDiskGuardDropsBelowLevel(t *testing.T) {
+	restoreStdHandlers(t)
+	var diagnostics syncBuffer
+	require.NoError(t, log.RegisterStderrHandler(log.NewWriterHandler(&diagnostics)))
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "guarded.log")
+
+	var free atomic.Uint64
+	h, err := log.NewFileHandler(path, log.WithDiskGuard(log.DiskGuard{
+		MinFree:  100,
+		Interval: 10 * time.Millisecond,
+		Probe:    func(string) (uint64, error) { return free.Load(), nil },
+	}))
+	require.NoError(t, err)
+	defer func() { require.NoError(t, h.Close()) }()
+
+	require.Eventually(t, func() bool { return strings.Contains(diagnostics.String(), "low disk space") },
+		time.Second, 10*time.Millisecond, "expected a diagnostic when the guard trips")
+
+	h.Handle("", log.NewLogMessage().Info().Msg("dropped"))
+	h.Handle("", log.NewLogMessage().Error().Msg("kept"))
+	require.NoError(t, h.Flush(context.Background()))
+	assert.Equal(t, uint64(1), h.Dropped())
+
+	free.Store(1000)
+	require.Eventually(t, func() bool { return strings.Contains(diagnostics.String(), "disk space recovered") },
+		time.Second, 10*time.Millisecond, "expected a diagnostic when the guard clears")
+
+	h.Handle("", log.NewLogMessage().Info().Msg("recovered"))
+	require.NoError(t, h.Flush(context.Background()))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "dropped")
+	assert.Contains(t, string(data), "kept")
+	assert.Contains(t, string(data), "recovered")
+
+	assert.Equal(t, 1, strings.Count(diagnostics.String(), "low disk space"), "expected a single diagnostic per change")
+	assert.Equal(t, 1, strings.Count(diagnostics.String(), "disk space recovered"), "expected a single diagnostic per change")
+}
+
+func TestDiskGuardFallback(t *testing.T) {
+	var fallback syncBuffer
+	fh := log.NewWriterHandler(&fallback)
+	require.NoError(t, fh.Start())
+	defer func() { require.NoError(t, fh.Close()) }()
+
+	var free atomic.Uint64
+	path := filepath.Join(t.TempDir(), "guarded.log")
+	h, err := log.NewFileHandler(path, log.WithDiskGuard(log.DiskGuard{
+		MinFree:  100,
+		Action:   log.DiskFallback,
+		Fallback: fh,
+		Interval: 10 * time.Millisecond,
+		Probe:    func(string) (uint64, error) { return free.Load(), nil },
+	}))
+	require.NoError(t, err)
+	defer func() { require.NoError(t, h.Close()) }()
+
+	require.Eventually(t, func() bool {
+		h.Handle("", log.NewLogMessage().Info().Msg("rerouted"))
+		require.NoError(t, h.Flush(context.Background()))
+		require.NoError(t, fh.Flush(context.Background()))
+		return strings.Contains(fallback.String(), "rerouted")
+	}, time.Second, 10*time.Millisecond, "expected messages to go to the fallback")
+}
+
+func TestDiskGuardPrunes(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now().UTC()
+
+	var archives []string
+	for _, age := range []time.Duration{3 * time.Hour, 2 * time.Hour, time.Hour} {
+		name := filepath.Join(dir, "app.log-"+now.Add(-age).Format("2006-01-02_15-04-05")+".gz")
+		require.NoError(t, os.WriteFile(name, []byte("old"), 0o600))
+		archives = append(archives, name)
+	}
+
+	// every removed archive frees 100 bytes
+	probe := func(string) (uint64, error) {
+		left, err := filepath.Glob(filepath.Join(dir, "app.log-*.gz"))
+		return uint64(100 * (len(archives) - len(left))), err
+	}
+	h, err := log.NewFileHandler(filepath.Join(dir, "app.log"), log.WithDiskGuard(log.DiskGuard{
+		MinFree:  200,
+		Action:   log.DiskPrune,
+		Interval: 10 * time.Millisecond,
+		Probe:    probe,
+	}))
+	require.NoError(t, err)
+	defer func() { require.NoError(t, h.Close()) }()
+
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(archives[1])
+		return err != nil
+	}, time.Second, 10*time.Millisecond, "expected archives to be pruned")
+
+	_, err = os.Stat(archives[0])
+	assert.ErrorIs(t, err, os.ErrNotExist, "expected the oldest archive to be pruned first")
+	_, err = os.Stat(archives[2])
+	assert.NoError(t, err, "expected pruning to stop once enough is free")
+}
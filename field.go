@@ -0,0 +1,263 @@
+package log
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Kind is the type held by a Value
+type Kind uint8
+
+const (
+	KindAny Kind = iota
+	KindString
+	KindInt64
+	KindUint64
+	KindFloat64
+	KindBool
+	KindDuration
+	KindTime
+	KindError
+	KindGroup
+)
+
+var kindNames = [...]string{"Any", "String", "Int64", "Uint64", "Float64", "Bool", "Duration", "Time", "Error", "Group"}
+
+func (k Kind) String() string {
+	if int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return "<unknown log.Kind>"
+}
+
+// Value holds a typed log field value
+//
+// Common types are stored without allocating,
+// anything else is kept as-is and formatted when written
+type Value struct {
+	kind Kind
+	num  uint64 // ints, floats, bools, durations and unix nano times
+	str  string
+	any  any // error, group, time location or arbitrary values
+}
+
+// Field is a single key-value pair of LogMessage.Meta
+type Field struct {
+	K string
+	V Value
+}
+
+// LogMessageMetaKV is the string metadata of earlier versions,
+// LogMessage.Meta holds Fields now, convert with Field
+//
+// Deprecated: use Field and its constructors like String
+type LogMessageMetaKV struct {
+	K, V string
+}
+
+// Field converts kv to a string Field
+func (kv LogMessageMetaKV) Field() Field { return String(kv.K, kv.V) }
+
+func StringValue(v string) Value          { return Value{kind: KindString, str: v} }
+func Int64Value(v int64) Value            { return Value{kind: KindInt64, num: uint64(v)} }
+func Uint64Value(v uint64) Value          { return Value{kind: KindUint64, num: v} }
+func Float64Value(v float64) Value        { return Value{kind: KindFloat64, num: math.Float64bits(v)} }
+func DurationValue(v time.Duration) Value { return Value{kind: KindDuration, num: uint64(v)} }
+func GroupValue(fs ...Field) Value        { return Value{kind: KindGroup, any: fs} }
+func BoolValue(v bool) Value {
+	var n uint64
+	if v {
+		n = 1
+	}
+	return Value{kind: KindBool, num: n}
+}
+
+func TimeValue(v time.Time) Value {
+	if v.IsZero() || v.Year() < 1678 || v.Year() > 2261 { // outside of UnixNano range
+		return Value{kind: KindTime, any: v}
+	}
+	return Value{kind: KindTime, num: uint64(v.UnixNano()), any: v.Location()}
+}
+
+// ErrorValue holds err, a nil err is written as <nil> by every formatter
+func ErrorValue(err error) Value { return Value{kind: KindError, any: err} }
+
+// AnyValue picks the most specific Kind for v
+func AnyValue(v any) Value {
+	switch v := v.(type) {
+	case string:
+		return StringValue(v)
+	case int:
+		return Int64Value(int64(v))
+	case int8:
+		return Int64Value(int64(v))
+	case int16:
+		return Int64Value(int64(v))
+	case int32:
+		return Int64Value(int64(v))
+	case int64:
+		return Int64Value(v)
+	case uint:
+		return Uint64Value(uint64(v))
+	case uint8:
+		return Uint64Value(uint64(v))
+	case uint16:
+		return Uint64Value(uint64(v))
+	case uint32:
+		return Uint64Value(uint64(v))
+	case uint64:
+		return Uint64Value(v)
+	case float32:
+		return Float64Value(float64(v))
+	case float64:
+		return Float64Value(v)
+	case bool:
+		return BoolValue(v)
+	case time.Duration:
+		return DurationValue(v)
+	case time.Time:
+		return TimeValue(v)
+	case error:
+		return ErrorValue(v)
+	case []Field:
+		return GroupValue(v...)
+	case Value:
+		return v
+	default:
+		return Value{kind: KindAny, any: v}
+	}
+}
+
+func (v Value) Kind() Kind { return v.kind }
+
+func (v Value) Int64() int64            { return int64(v.num) }
+func (v Value) Uint64() uint64          { return v.num }
+func (v Value) Float64() float64        { return math.Float64frombits(v.num) }
+func (v Value) Bool() bool              { return v.num == 1 }
+func (v Value) Duration() time.Duration { return time.Duration(v.num) }
+
+func (v Value) Time() time.Time {
+	switch a := v.any.(type) {
+	case time.Time:
+		return a
+	case *time.Location:
+		return time.Unix(0, int64(v.num)).In(a)
+	}
+	return time.Time{}
+}
+
+func (v Value) Error() error {
+	err, _ := v.any.(error)
+	return err
+}
+
+func (v Value) Group() []Field {
+	fs, _ := v.any.([]Field)
+	return fs
+}
+
+// Any returns v as an ordinary Go value
+func (v Value) Any() any {
+	switch v.kind {
+	case KindString:
+		return v.str
+	case KindInt64:
+		return v.Int64()
+	case KindUint64:
+		return v.num
+	case KindFloat64:
+		return v.Float64()
+	case KindBool:
+		return v.Bool()
+	case KindDuration:
+		return v.Duration()
+	case KindTime:
+		return v.Time()
+	default:
+		return v.any
+	}
+}
+
+// String formats v as text,
+// groups are formatted as {k=v, k2=v2}
+func (v Value) String() string {
+	if v.kind == KindString {
+		return v.str
+	}
+	return string(v.appendText(nil))
+}
+
+func (v Value) appendText(buf []byte) []byte {
+	switch v.kind {
+	case KindString:
+		return append(buf, v.str...)
+	case KindInt64:
+		return strconv.AppendInt(buf, v.Int64(), 10)
+	case KindUint64:
+		return strconv.AppendUint(buf, v.num, 10)
+	case KindFloat64:
+		return strconv.AppendFloat(buf, v.Float64(), 'g', -1, 64)
+	case KindBool:
+		return strconv.AppendBool(buf, v.Bool())
+	case KindDuration:
+		return append(buf, v.Duration().String()...)
+	case KindTime:
+		return v.Time().AppendFormat(buf, time.RFC3339Nano)
+	case KindError:
+		if err := v.Error(); err != nil {
+			return append(buf, err.Error()...)
+		}
+		return append(buf, "<nil>"...)
+	case KindGroup:
+		buf = append(buf, '{')
+		for i, f := range v.Group() {
+			if i > 0 {
+				buf = append(buf, ", "...)
+			}
+			buf = append(buf, f.K...)
+			buf = append(buf, '=')
+			buf = f.V.appendText(buf)
+		}
+		return append(buf, '}')
+	default:
+		return fmt.Append(buf, v.any)
+	}
+}
+
+func (f Field) String() string { return f.K + "=" + f.V.String() }
+
+// Field constructors
+
+func String(key, v string) Field              { return Field{K: key, V: StringValue(v)} }
+func Int(key string, v int) Field             { return Field{K: key, V: Int64Value(int64(v))} }
+func Int64(key string, v int64) Field         { return Field{K: key, V: Int64Value(v)} }
+func Uint64(key string, v uint64) Field       { return Field{K: key, V: Uint64Value(v)} }
+func Float64(key string, v float64) Field     { return Field{K: key, V: Float64Value(v)} }
+func Bool(key string, v bool) Field           { return Field{K: key, V: BoolValue(v)} }
+func Dur(key string, v time.Duration) Field   { return Field{K: key, V: DurationValue(v)} }
+func Time(key string, v time.Time) Field      { return Field{K: key, V: TimeValue(v)} }
+func Any(key string, v any) Field             { return Field{K: key, V: AnyValue(v)} }
+func Group(key string, fields ...Field) Field { return Field{K: key, V: GroupValue(fields...)} }
+
+// Err creates an "error" field
+func Err(err error) Field { return Field{K: "error", V: ErrorValue(err)} }
+
+// NamedErr creates an error field under key
+func NamedErr(key string, err error) Field { return Field{K: key, V: ErrorValue(err)} }
+
+// flattenFields expands groups into dotted keys
+func flattenFields(prefix string, fs []Field, fn func(key string, v Value)) {
+	for _, f := range fs {
+		key := f.K
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if f.V.kind == KindGroup {
+			flattenFields(key, f.V.Group(), fn)
+			continue
+		}
+		fn(key, f.V)
+	}
+}
@@ -1,12 +1,9 @@
 package log
 
 import (
-	"bytes"
-	"compress/gzip"
 	"context"
 	"errors"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -23,8 +20,10 @@ type refCountedFileHandler struct {
 }
 
 // NewFileHandler creates a new FileHandler and starts it.
-func NewFileHandler(path string) (*FileHandler, error) {
-	return newRefCounted(newFileHandler(path))
+//
+// Handlers are shared per path, opts only apply to the first handler of a path
+func NewFileHandler(path string, opts ...FileOption) (*FileHandler, error) {
+	return newRefCounted(newFileHandler(path, opts...))
 }
 
 func newRefCounted(fh *FileHandler) (*FileHandler, error) {
@@ -62,6 +61,10 @@ func newRefCounted(fh *FileHandler) (*FileHandler, error) {
 				_ = ptr.Close()
 				rh.handler.filePtr = nil
 			}
+			if lock := rh.handler.lockPtr; lock != nil {
+				_ = lock.Close()
+				rh.handler.lockPtr = nil
+			}
 
 			fileHandlers.Delete(newPth)
 		})
@@ -85,19 +88,49 @@ type FileHandler struct {
 	logDir      string
 	logFilename string
 	filePtr     *os.File
-	maxFileSize int64 // exceeding this size will trigger log rotation. defaults to 10MB. set to 0 to disable
+	maxFileSize atomic.Int64 // exceeding this size will trigger log rotation. set to 0 to disable
+	written     int64        // size of filePtr as far as we know, covered by muFile
+
+	schedule Schedule       // time-based rotations, nil to disable
+	location *time.Location // schedule timezone, defaults to UTC
+
+	naming      ArchiveNaming          // where rotated logs go and how they are named
+	compression Compression            // how rotated logs are compressed
+	retention   Retention              // limits on rotated archives
+	rotateHooks []func(archive string) // ran on every finished archive
+	processLock bool                   // coordinate with other processes, see WithProcessLock
+	lockPtr     *os.File               // the process lock while running, covered by muFile
+	closing     bool                   // no archives are started once set, covered by muFile
+	nextSeq     int                    // next sequence number of ArchiveNaming.Sequence, covered by muFile
+
+	diskGuard     *DiskGuard    // nil unless WithDiskGuard
+	diskCheck     chan struct{} // asks the disk guard to check right away
+	lowDisk       atomic.Bool
+	diskFullWrite atomic.Bool    // a write ran out of space since the last check
+	diskReported  bool           // low disk space was logged, only used by the disk guard
+	compressing   sync.WaitGroup // background compressions of rotated logs
+
+	fileMode os.FileMode // mode of the log and its archives, defaults to 0o600
+	dirMode  os.FileMode // mode of created directories, defaults to 0o700
 
 	release   func() bool // returns true if the handler is no longer in use
 	onRelease func()
 }
 
-func newFileHandler(path string) *FileHandler {
+func newFileHandler(path string, opts ...FileOption) *FileHandler {
 	f := &FileHandler{
 		logDir:      filepath.Dir(path),
 		logFilename: filepath.Base(path),
+		location:    time.UTC,
+		fileMode:    0o600,
+		dirMode:     0o700,
+	}
+	for _, opt := range opts {
+		opt(f)
 	}
 
 	f.BaseHandler = BaseHandler{
+		Name: "file handler " + path,
 		CancelPreFunc: func(ctx context.Context, lh LogHandler) error {
 			if f.release != nil {
 				if !f.release() {
@@ -108,6 +141,11 @@ func newFileHandler(path string) *FileHandler {
 			return nil
 		},
 		CloseFunc: func(ctx context.Context, lh LogHandler) error {
+			f.muFile.Lock()
+			f.closing = true
+			f.muFile.Unlock()
+
+			f.compressing.Wait()
 			if f.onRelease != nil {
 				f.onRelease()
 			}
@@ -118,13 +156,33 @@ func newFileHandler(path string) *FileHandler {
 			if base == "." {
 				return ErrMissingLogFilename
 			}
+			if f.diskGuard != nil && f.diskGuard.Probe == nil {
+				return ErrDiskGuardUnsupported
+			}
 
 			logfile, err := f.ensureLogFile()
 			if err != nil {
 				return fmt.Errorf("failed to open log file: %w", err)
 			}
+			if f.processLock {
+				if f.lockPtr, err = f.openProcessLock(); err != nil {
+					_ = logfile.Close()
+					return err
+				}
+			}
 			f.filePtr = logfile
+			if info, err := logfile.Stat(); err == nil {
+				f.written = info.Size()
+			}
 
+			f.muFile.Lock()
+			f.closing = false
+			pending, err := f.pendingArchives()
+			f.compressInBackground(pending...) // also prunes, so it runs even without pending archives
+			f.muFile.Unlock()
+			if err != nil {
+				Error().Msgf("failed to check rotated logs: %v", err).Send()
+			}
 			return nil
 		},
 		HandleFunc: func(ctx context.Context, msg *LogMessage) error {
@@ -134,14 +192,62 @@ func newFileHandler(path string) *FileHandler {
 			if f.filePtr == nil {
 				panic("FileHandler: filePtr is nil")
 			}
+			if f.guarded(msg) {
+				return nil
+			}
 
-			_, err := f.filePtr.WriteString(msg.String(""))
+			b, err := f.Format(msg)
 			if err != nil {
 				return err
 			}
+
+			unlock, err := f.lockProcesses(false)
+			if err != nil {
+				return err
+			}
+			defer unlock()
+			if f.lockPtr != nil {
+				// follow rotations of other processes, also counts their writes
+				if err := f.reopenIfMoved(); err != nil {
+					return err
+				}
+			}
+
+			// rotate before the write would cross the limit,
+			// a single line larger than the limit still gets a file of its own
+			var rotateErr error
+			if limit := f.GetMaxFileSize(); limit > 0 && f.written > 0 && f.written+int64(len(b)) > limit {
+				var pending string
+				if _, rotateErr = f.lockProcesses(true); rotateErr == nil { // released by unlock
+					if pending, rotateErr = f.swapLogFile(); pending != "" {
+						f.compressInBackground(pending)
+					}
+				}
+			}
+
+			n, err := f.filePtr.Write(b)
+			f.written += int64(n)
+			if err != nil {
+				if f.diskFull(msg, err) {
+					return nil
+				}
+				return err
+			}
+			if rotateErr != nil {
+				return fmt.Errorf("failed to rotate log: %w", rotateErr)
+			}
 			return nil
 		},
-		Subprocesses: []func(context.Context) error{f.logRotater},
+		FlushFunc: func(ctx context.Context, lh LogHandler) error {
+			f.muFile.Lock()
+			defer f.muFile.Unlock()
+
+			if f.filePtr == nil {
+				return nil
+			}
+			return f.filePtr.Sync()
+		},
+		Subprocesses: []func(context.Context) error{f.logRotater, f.guardDisk},
 	}
 
 	return f
@@ -151,78 +257,267 @@ func (f *FileHandler) logRotater(ctx context.Context) error {
 	ticker := time.NewTicker(time.Minute)
 	defer ticker.Stop()
 
+	var (
+		timer     *time.Timer
+		scheduled <-chan time.Time // stays nil without a schedule
+	)
+	if d, ok := f.untilNextRotation(); ok {
+		timer = time.NewTimer(d)
+		defer timer.Stop()
+		scheduled = timer.C
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
 			return nil
 
+		case <-scheduled:
+			f.tryRotate()
+
+			if d, ok := f.untilNextRotation(); ok {
+				timer.Reset(d)
+			} else {
+				scheduled = nil
+			}
+
 		case <-ticker.C:
-			maxFilesize := f.GetMaxFileSize()
-			if maxFilesize == 0 {
-				return nil
+			if err := f.checkFile(); err != nil {
+				Error().Msgf("failed to check log file: %v", err).Send()
 			}
+		}
+	}
+}
 
-			logDir, logFilename := f.GetLogfileLocation()
-			logPath := filepath.Join(logDir, logFilename)
-			rotatedName := fmt.Sprintf("%s-%s.gz", logFilename, time.Now().UTC().Format("2006-01-02_15-04-05"))
-			rotatedPath := filepath.Join(logDir, rotatedName)
+// untilNextRotation returns how long until the next scheduled rotation
+func (f *FileHandler) untilNextRotation() (time.Duration, bool) {
+	if f.schedule == nil {
+		return 0, false
+	}
 
-			info, err := os.Stat(logPath)
-			if err != nil {
-				if os.IsNotExist(err) {
-					f.muFile.Lock()
-					_, err := f.ensureLogFile()
-					f.muFile.Unlock()
+	next := f.schedule.Next(time.Now().In(f.location))
+	if next.IsZero() {
+		return 0, false
+	}
+	return time.Until(next), true
+}
 
-					if err != nil {
-						return fmt.Errorf("failed to recreate missing log file, killing rotation: %w", err)
-					}
+// checkFile reopens the log once it was moved away
+// and rotates it once it grew past maxFileSize
+//
+// writes are counted by HandleFunc, this is a safety net
+// for changes made outside of the handler, like logrotate or truncation
+func (f *FileHandler) checkFile() error {
+	f.muFile.Lock()
+	err := f.reopenIfMoved()
+	size := f.written
+	f.muFile.Unlock()
+	if err != nil {
+		return err
+	}
 
-					continue
-				}
+	if limit := f.GetMaxFileSize(); limit > 0 && size > limit {
+		f.tryRotate()
+	}
+	return nil
+}
 
-				f.wg.Done()
-				return fmt.Errorf("failed to stat log file, killing rotation: %w", err)
-			}
+// reopenIfMoved reopens the log when its path no longer leads to filePtr
+//
+// callers responsibility to hold muFile
+func (f *FileHandler) reopenIfMoved() error {
+	if f.filePtr == nil {
+		return nil
+	}
 
-			if info.Size() <= maxFilesize {
-				continue
-			}
+	moved, err := f.moved()
+	if err != nil || !moved {
+		return err
+	}
+	return f.reopen()
+}
 
-			f.muFile.Lock()
-			original, err := os.Open(filepath.Clean(logPath))
-			if err != nil {
-				f.muFile.Unlock()
-				Error().Msgf("failed to open log for rotation: %v", err).Send()
-				continue
-			}
+// moved reports whether the log's path no longer leads to filePtr,
+// it also refreshes written
+//
+// callers responsibility to hold muFile
+func (f *FileHandler) moved() (bool, error) {
+	current, err := f.filePtr.Stat()
+	if err != nil {
+		return false, fmt.Errorf("failed to stat log file: %w", err)
+	}
+	f.written = current.Size()
 
-			var buf bytes.Buffer
-			gz := gzip.NewWriter(&buf)
-			_, err = io.Copy(gz, original)
-			_ = original.Close()
-			_ = gz.Close()
-			if err != nil {
-				f.muFile.Unlock()
-				Error().Msgf("failed to compress rotated log: %v", err).Send()
-				continue
-			}
+	onDisk, err := os.Stat(filepath.Join(f.getLogfileLocation()))
+	if err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("failed to stat log file: %w", err)
+	}
+	return err != nil || !os.SameFile(current, onDisk), nil
+}
 
-			if err := os.WriteFile(rotatedPath, buf.Bytes(), 0o600); err != nil {
-				f.muFile.Unlock()
-				Error().Msgf("failed to write rotated log file: %v", err).Send()
-				continue
-			}
+// lockProcesses takes the lock shared with other processes, see WithProcessLock,
+// a shared lock already held is converted
+//
+// callers responsibility to hold muFile
+func (f *FileHandler) lockProcesses(exclusive bool) (unlock func(), err error) {
+	lock := f.lockPtr
+	if lock == nil {
+		return func() {}, nil
+	}
+	if err := lockFile(lock, exclusive); err != nil {
+		return nil, fmt.Errorf("failed to lock log: %w", err)
+	}
+	return func() { _ = unlockFile(lock) }, nil
+}
 
-			if err := os.Truncate(logPath, 0); err != nil {
-				Error().Msgf("failed to truncate original log after rotation: %v", err).Send()
-			}
+// Reopen closes the log and opens it again by its path,
+// for external tools like logrotate that move the log away
+//
+// A moved log is also noticed by a check running once a minute,
+// until then writes still go to the moved file.
+// See ReopenFiles to reopen all file handlers at once
+func (f *FileHandler) Reopen() error {
+	f.muFile.Lock()
+	defer f.muFile.Unlock()
+	return f.reopen()
+}
 
-			f.muFile.Unlock()
+// callers responsibility to hold muFile
+func (f *FileHandler) reopen() error {
+	if f.filePtr == nil {
+		return ErrNotStarted
+	}
+
+	fresh, err := f.ensureLogFile()
+	if err != nil {
+		return fmt.Errorf("failed to reopen log file: %w", err)
+	}
+
+	_ = f.filePtr.Close()
+	f.filePtr = fresh
+	f.written = 0
+	if info, err := fresh.Stat(); err == nil {
+		f.written = info.Size()
+	}
+	return nil
+}
+
+// ReopenFiles reopens the logs of all running file handlers
+func ReopenFiles() error {
+	var errs []error
+	fileHandlers.Range(func(_, val any) bool {
+		err := val.(*refCountedFileHandler).handler.Reopen()
+		if err != nil && !errors.Is(err, ErrNotStarted) {
+			errs = append(errs, err)
 		}
+		return true
+	})
+	return errors.Join(errs...)
+}
+
+func (f *FileHandler) tryRotate() {
+	if err := f.rotate(); err != nil {
+		Error().Msgf("failed to rotate log: %v", err).Send()
 	}
 }
 
+// rotate moves the log aside and continues in a fresh file,
+// the moved log is compressed and pruned in the background
+func (f *FileHandler) rotate() error {
+	_, _, err := f.startRotation()
+	return err
+}
+
+// Rotate rotates the log now and waits for the archive to be finished,
+// returning its path, or an empty path if the log was empty
+// or another process sharing it rotated it first
+//
+// Writes only wait for the log to be moved, once ctx is done
+// the archive is still finished in the background. Rotate hooks run afterwards
+func (f *FileHandler) Rotate(ctx context.Context) (string, error) {
+	pending, results, err := f.startRotation()
+	if err != nil || pending == "" {
+		return "", err
+	}
+
+	select {
+	case res, ok := <-results:
+		if !ok {
+			return "", fmt.Errorf("failed to finish archive %s", pending)
+		}
+		return res.path, res.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// startRotation swaps the log and starts finishing the pending archive,
+// pending is empty if nothing was rotated
+func (f *FileHandler) startRotation() (pending string, results <-chan archiveResult, err error) {
+	f.muFile.Lock()
+	defer f.muFile.Unlock()
+
+	if f.closing {
+		return "", nil, ErrNotStarted
+	}
+	unlock, err := f.lockProcesses(true)
+	if err != nil {
+		return "", nil, err
+	}
+	defer unlock()
+
+	if pending, err = f.swapLogFile(); err != nil || pending == "" {
+		return "", nil, err
+	}
+	return pending, f.compressInBackground(pending), nil
+}
+
+// swapLogFile renames the log to a pending archive and opens a new one in its place,
+// the returned path is empty if the log was empty or was already moved away
+//
+// writes are locked out by muFile, and those of other processes by the exclusive process lock,
+// so every line ends up in exactly one of both files.
+// callers responsibility to hold muFile
+func (f *FileHandler) swapLogFile() (string, error) {
+	if f.filePtr == nil {
+		return "", ErrNotStarted
+	}
+
+	moved, err := f.moved()
+	if err != nil {
+		return "", fmt.Errorf("failed to stat log for rotation: %w", err)
+	}
+	if moved {
+		return "", f.reopen() // rotated by another process or logrotate
+	}
+	if f.written == 0 {
+		return "", nil // nothing to archive
+	}
+
+	logPath := filepath.Join(f.getLogfileLocation())
+	pending, err := f.pendingArchivePath(time.Now())
+	if err != nil {
+		return "", fmt.Errorf("failed to prepare archive for rotation: %w", err)
+	}
+	if err := f.renameOpenLog(logPath, pending); err != nil {
+		return "", fmt.Errorf("failed to move log for rotation: %w", err)
+	}
+
+	fresh, err := f.openLogFile()
+	if err != nil {
+		// the old file is still open, keep writing to it under its old name
+		if rerr := f.renameOpenLog(pending, logPath); rerr != nil {
+			return "", fmt.Errorf("failed to open new log after rotation: %w", errors.Join(err, rerr))
+		}
+		return "", fmt.Errorf("failed to open new log after rotation: %w", err)
+	}
+
+	_ = f.filePtr.Close()
+	f.filePtr = fresh
+	f.written = 0
+	return pending, nil
+}
+
 func (f *FileHandler) getLogfileLocation() (dir, base string) {
 	return f.logDir, f.logFilename
 }
@@ -233,21 +528,14 @@ func (f *FileHandler) GetLogfileLocation() (dir, base string) {
 	return f.getLogfileLocation()
 }
 
-func (f *FileHandler) SetMaxFileSize(size int64) {
-	f.mu.Lock()
-	f.maxFileSize = size
-	f.mu.Unlock()
-}
-
-func (f *FileHandler) GetMaxFileSize() int64 {
-	f.mu.RLock()
-	defer f.mu.RUnlock()
-	return f.maxFileSize
-}
+func (f *FileHandler) SetMaxFileSize(size int64) { f.maxFileSize.Store(size) }
+func (f *FileHandler) GetMaxFileSize() int64     { return f.maxFileSize.Load() }
 
 func (f *FileHandler) SetLogfileLocation(dir, base string) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
+	f.muFile.Lock()
+	defer f.muFile.Unlock()
 
 	path := filepath.Join(dir, base)
 	if path == "." {
@@ -264,7 +552,7 @@ func (f *FileHandler) ensureLogDir() error {
 		return nil
 	}
 
-	return os.MkdirAll(filepath.Clean(f.logDir), 0o700)
+	return os.MkdirAll(filepath.Clean(f.logDir), f.dirMode)
 }
 
 func (f *FileHandler) ensureLogFile() (*os.File, error) {
@@ -295,10 +583,25 @@ func (f *FileHandler) ensureLogFile() (*os.File, error) {
 	return f.openLogFile()
 }
 
+// openProcessLock opens the lock file shared with other processes,
+// failing on platforms without advisory locks
+func (f *FileHandler) openProcessLock() (*os.File, error) {
+	lock, err := os.OpenFile(filepath.Join(f.logDir, f.logFilename+".lock"), os.O_CREATE|os.O_RDWR, f.fileMode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+	if err := lockFile(lock, false); err != nil {
+		_ = lock.Close()
+		return nil, fmt.Errorf("failed to lock log: %w", err)
+	}
+	_ = unlockFile(lock)
+	return lock, nil
+}
+
 func (f *FileHandler) openLogFile() (*os.File, error) {
 	return os.OpenFile(
 		filepath.Join(f.logDir, f.logFilename),
 		os.O_APPEND|os.O_CREATE|os.O_WRONLY,
-		0o600,
+		f.fileMode,
 	)
 }
@@ -0,0 +1,75 @@
+package log
+
+import (
+	"os"
+	"time"
+)
+
+// FileOption configures a FileHandler when it is created
+type FileOption func(*FileHandler)
+
+// WithSchedule rotates the log on a schedule, on top of size-based rotations
+//
+//	log.WithSchedule(log.Daily)
+//	log.WithSchedule(log.MustParseCron("0 */6 * * *"))
+func WithSchedule(s Schedule) FileOption {
+	return func(f *FileHandler) { f.schedule = s }
+}
+
+// WithScheduleLocation sets the timezone the schedule runs in, defaults to UTC
+func WithScheduleLocation(loc *time.Location) FileOption {
+	return func(f *FileHandler) {
+		if loc != nil {
+			f.location = loc
+		}
+	}
+}
+
+// WithFileMode sets the mode of the log and its archives, defaults to 0o600
+//
+// Like with os.OpenFile the umask still applies
+func WithFileMode(mode os.FileMode) FileOption {
+	return func(f *FileHandler) {
+		if mode != 0 {
+			f.fileMode = mode.Perm()
+		}
+	}
+}
+
+// WithDirMode sets the mode of directories created for the log and its archives,
+// defaults to 0o700
+func WithDirMode(mode os.FileMode) FileOption {
+	return func(f *FileHandler) {
+		if mode != 0 {
+			f.dirMode = mode.Perm()
+		}
+	}
+}
+
+// WithRotateHook runs fn with the path of every finished archive,
+// including the ones left behind by a previous run
+//
+// Hooks run in the background before retention is applied and may log,
+// closing the handler does not wait for them
+func WithRotateHook(fn func(archive string)) FileOption {
+	return func(f *FileHandler) {
+		if fn != nil {
+			f.rotateHooks = append(f.rotateHooks, fn)
+		}
+	}
+}
+
+// WithProcessLock lets several processes share one log path,
+// coordinating through an advisory lock on <path>.lock. Only supported on linux, darwin, illumos and the BSDs
+//
+// Writes take the lock shared and follow rotations made by other processes,
+// rotations take it exclusively so only one process rotates at a time
+func WithProcessLock() FileOption {
+	return func(f *FileHandler) { f.processLock = true }
+}
+
+// WithMaxFileSize rotates the log before it would grow past size bytes,
+// 0 disables size-based rotations
+func WithMaxFileSize(size int64) FileOption {
+	return func(f *FileHandler) { f.maxFileSize.Store(max(size, 0)) }
+}
@@ -0,0 +1,12 @@
+//go:build !(linux || darwin || freebsd || openbsd || netbsd || dragonfly || illumos)
+
+package log
+
+import (
+	"errors"
+	"os"
+)
+
+func lockFile(*os.File, bool) error      { return errors.ErrUnsupported }
+func tryLockFile(*os.File) (bool, error) { return false, errors.ErrUnsupported }
+func unlockFile(*os.File) error          { return errors.ErrUnsupported }
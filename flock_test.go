@@ -0,0 +1,76 @@
+//go:build linux || darwin || freebsd || openbsd || netbsd || dragonfly || illumos
+
+package log_test
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/lattesec/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessLockSharedLog(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "real"), 0o700))
+	require.NoError(t, os.Symlink("real", filepath.Join(dir, "link")))
+
+	// handlers are shared per path, a symlink gets a second one
+	// with its own file descriptors, like another process would
+	const maxSize = 512
+	var handlers []*log.FileHandler
+	for _, sub := range []string{"real", "link"} {
+		h, err := log.NewFileHandler(filepath.Join(dir, sub, "shared.log"), log.WithProcessLock())
+		require.NoError(t, err)
+		h.SetMaxFileSize(maxSize)
+		handlers = append(handlers, h)
+	}
+
+	const lines = 500
+	var wg sync.WaitGroup
+	for i, h := range handlers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range lines {
+				h.Handle("", log.NewLogMessage().Info().Msgf("writer %d line %d", i, j))
+			}
+		}()
+	}
+	wg.Wait()
+	for _, h := range handlers {
+		require.NoError(t, h.Close())
+	}
+
+	archives, err := filepath.Glob(filepath.Join(dir, "real", "shared.log-*"))
+	require.NoError(t, err)
+	assert.Greater(t, len(archives), 2, "expected several rotations")
+
+	var all strings.Builder
+	for _, a := range archives {
+		require.True(t, strings.HasSuffix(a, ".gz"), "expected %s to be compressed", a)
+		all.WriteString(readGzip(t, a))
+	}
+	current, err := os.ReadFile(filepath.Join(dir, "real", "shared.log"))
+	require.NoError(t, err)
+	all.Write(current)
+
+	seen := map[string]int{}
+	sc := bufio.NewScanner(strings.NewReader(all.String()))
+	for sc.Scan() {
+		if i := strings.Index(sc.Text(), "writer "); i >= 0 {
+			seen[sc.Text()[i:]]++
+		}
+	}
+	for i := range handlers {
+		for j := range lines {
+			assert.Equal(t, 1, seen[fmt.Sprintf("writer %d line %d", i, j)], "writer %d line %d", i, j)
+		}
+	}
+}
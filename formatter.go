@@ -0,0 +1,56 @@
+package log
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Formatters decide how a LogMessage
+// is laid out before a handler writes it
+type Formatter interface {
+	Format(msg *LogMessage) ([]byte, error)
+}
+
+// FormatterFunc allows using ordinary functions as Formatters
+type FormatterFunc func(msg *LogMessage) ([]byte, error)
+
+func (fn FormatterFunc) Format(msg *LogMessage) ([]byte, error) { return fn(msg) }
+
+// DefaultFormatter is used by handlers without a Formatter set
+var DefaultFormatter Formatter = TextFormatter{}
+
+// TextFormatter is the human-readable layout
+//
+// 2006-01-02T15:04:05.999999999Z [LEVEL] name: message {k=v, k2=v2}
+type TextFormatter struct{}
+
+func (TextFormatter) Format(msg *LogMessage) ([]byte, error) {
+	return []byte(formatText(msg.loggerName, msg)), nil
+}
+
+func formatText(loggerName string, lm *LogMessage) string {
+	var metaStr string
+	if len(lm.Meta) > 0 {
+		metaStr = " " + GroupValue(lm.Meta...).String()
+	}
+
+	var debugStr string
+	if lm.trace != "" || lm.caller != "" {
+		debugStr = fmt.Sprintf("\n==== DEBUG ====\nCaller: %s\nTrace: %s", lm.caller, lm.trace) + "===== END =====\n\n"
+	}
+
+	var ts string // records from slog may have no time
+	if !lm.Timestamp.IsZero() {
+		ts = lm.Timestamp.Format(time.RFC3339Nano) + " "
+	}
+
+	return strings.TrimSuffix(fmt.Sprintf("%s[%s] %s: %s%s%s",
+		ts,
+		lm.LevelString(),
+		loggerName,
+		strings.TrimSuffix(lm.Message, "\n"),
+		metaStr,
+		debugStr,
+	), "\n") + "\n"
+}
@@ -0,0 +1,158 @@
+package log
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// JSONFormatter writes newline-delimited JSON
+//
+// {"time":"...","level":"INFO","logger":"name","msg":"message","k":"v","caller":"...","stack":"..."}
+//
+// Meta entries are written as top-level fields in the order they were added,
+// numbers and booleans are kept as JSON numbers and booleans and groups become objects.
+// "time" is omitted when the message has no timestamp
+type JSONFormatter struct {
+	TimeFormat string // defaults to time.RFC3339Nano
+}
+
+func (jf JSONFormatter) Format(msg *LogMessage) ([]byte, error) {
+	timeFormat := jf.TimeFormat
+	if timeFormat == "" {
+		timeFormat = time.RFC3339Nano
+	}
+
+	buf := make([]byte, 0, 256)
+	buf = append(buf, '{')
+	if !msg.Timestamp.IsZero() {
+		buf = append(buf, `"time":`...)
+		buf = appendJSONString(buf, msg.Timestamp.Format(timeFormat))
+		buf = append(buf, ',')
+	}
+	buf = append(buf, `"level":`...)
+	buf = appendJSONString(buf, msg.LevelString())
+	buf = append(buf, `,"logger":`...)
+	buf = appendJSONString(buf, msg.loggerName)
+	buf = append(buf, `,"msg":`...)
+	buf = appendJSONString(buf, strings.TrimSuffix(msg.Message, "\n"))
+
+	for _, m := range msg.Meta {
+		buf = append(buf, ',')
+		buf = appendJSONField(buf, m)
+	}
+
+	if msg.caller != "" {
+		buf = append(buf, `,"caller":`...)
+		buf = appendJSONString(buf, strings.TrimPrefix(msg.caller, "trace: "))
+	}
+	if msg.trace != "" {
+		buf = append(buf, `,"stack":`...)
+		buf = appendJSONString(buf, strings.TrimPrefix(msg.trace, "stack:\n"))
+	}
+
+	buf = append(buf, '}', '\n')
+	return buf, nil
+}
+
+func appendJSONField(buf []byte, f Field) []byte {
+	buf = appendJSONString(buf, f.K)
+	buf = append(buf, ':')
+	return appendJSONValue(buf, f.V)
+}
+
+func appendJSONValue(buf []byte, v Value) []byte {
+	switch v.kind {
+	case KindString:
+		return appendJSONString(buf, v.str)
+	case KindInt64:
+		return strconv.AppendInt(buf, v.Int64(), 10)
+	case KindUint64:
+		return strconv.AppendUint(buf, v.num, 10)
+	case KindFloat64:
+		f := v.Float64()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return appendJSONString(buf, strconv.FormatFloat(f, 'g', -1, 64))
+		}
+		return strconv.AppendFloat(buf, f, 'g', -1, 64)
+	case KindBool:
+		return strconv.AppendBool(buf, v.Bool())
+	case KindGroup:
+		buf = append(buf, '{')
+		for i, f := range v.Group() {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = appendJSONField(buf, f)
+		}
+		return append(buf, '}')
+	case KindAny:
+		if v.any == nil {
+			return append(buf, "null"...)
+		}
+		if b, err := json.Marshal(v.any); err == nil {
+			return append(buf, b...)
+		}
+	}
+	return appendJSONString(buf, v.String())
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendJSONString appends s as a quoted JSON string,
+// invalid UTF-8 is replaced with U+FFFD
+func appendJSONString(buf []byte, s string) []byte {
+	buf = append(buf, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' {
+				i++
+				continue
+			}
+
+			buf = append(buf, s[start:i]...)
+			switch c {
+			case '"', '\\':
+				buf = append(buf, '\\', c)
+			case '\n':
+				buf = append(buf, '\\', 'n')
+			case '\r':
+				buf = append(buf, '\\', 'r')
+			case '\t':
+				buf = append(buf, '\\', 't')
+			default:
+				buf = append(buf, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xf])
+			}
+			i++
+			start = i
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			buf = append(buf, s[start:i]...)
+			buf = append(buf, `\ufffd`...)
+			i += size
+			start = i
+			continue
+		}
+
+		// U+2028 and U+2029 break JavaScript parsers
+		if r == '\u2028' || r == '\u2029' {
+			buf = append(buf, s[start:i]...)
+			buf = append(buf, '\\', 'u', '2', '0', '2', hexDigits[r&0xf])
+			i += size
+			start = i
+			continue
+		}
+
+		i += size
+	}
+	buf = append(buf, s[start:]...)
+	return append(buf, '"')
+}
@@ -0,0 +1,112 @@
+package log
+
+import (
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// LogfmtFormatter writes logfmt lines
+//
+// ts=... level=info logger=name msg="message" k=v caller=... stack="..."
+//
+// Groups are flattened into dotted keys (group.key=value),
+// values containing spaces, '=', quotes or control characters are quoted and escaped,
+// ts= is skipped for messages without a timestamp
+type LogfmtFormatter struct {
+	TimeFormat string // defaults to time.RFC3339Nano
+}
+
+func (lf LogfmtFormatter) Format(msg *LogMessage) ([]byte, error) {
+	timeFormat := lf.TimeFormat
+	if timeFormat == "" {
+		timeFormat = time.RFC3339Nano
+	}
+
+	buf := make([]byte, 0, 256)
+	if !msg.Timestamp.IsZero() {
+		buf = append(buf, "ts="...)
+		buf = appendLogfmtValue(buf, msg.Timestamp.Format(timeFormat))
+		buf = append(buf, ' ')
+	}
+	buf = append(buf, "level="...)
+	buf = appendLogfmtValue(buf, strings.ToLower(msg.LevelString()))
+	buf = append(buf, " logger="...)
+	buf = appendLogfmtValue(buf, msg.loggerName)
+	buf = append(buf, " msg="...)
+	buf = appendLogfmtValue(buf, strings.TrimSuffix(msg.Message, "\n"))
+
+	flattenFields("", msg.Meta, func(key string, v Value) {
+		buf = append(buf, ' ')
+		buf = appendLogfmtKey(buf, key)
+		buf = append(buf, '=')
+		buf = appendLogfmtValue(buf, v.String())
+	})
+
+	if msg.caller != "" {
+		buf = append(buf, " caller="...)
+		buf = appendLogfmtValue(buf, strings.TrimPrefix(msg.caller, "trace: "))
+	}
+	if msg.trace != "" {
+		buf = append(buf, " stack="...)
+		buf = appendLogfmtValue(buf, strings.TrimPrefix(msg.trace, "stack:\n"))
+	}
+
+	buf = append(buf, '\n')
+	return buf, nil
+}
+
+// keys cannot be quoted, so offending characters are replaced
+func appendLogfmtKey(buf []byte, k string) []byte {
+	if k == "" {
+		return append(buf, '_')
+	}
+	for _, r := range k {
+		if r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError || !unicode.IsPrint(r) {
+			buf = append(buf, '_')
+			continue
+		}
+		buf = utf8.AppendRune(buf, r)
+	}
+	return buf
+}
+
+func appendLogfmtValue(buf []byte, v string) []byte {
+	if !logfmtNeedsQuote(v) {
+		return append(buf, v...)
+	}
+
+	buf = append(buf, '"')
+	for _, r := range v {
+		switch r {
+		case '"', '\\':
+			buf = append(buf, '\\', byte(r))
+		case '\n':
+			buf = append(buf, '\\', 'n')
+		case '\r':
+			buf = append(buf, '\\', 'r')
+		case '\t':
+			buf = append(buf, '\\', 't')
+		default:
+			if r < ' ' || r == 0x7f {
+				buf = append(buf, '\\', 'u', '0', '0', hexDigits[r>>4], hexDigits[r&0xf])
+				continue
+			}
+			buf = utf8.AppendRune(buf, r)
+		}
+	}
+	return append(buf, '"')
+}
+
+func logfmtNeedsQuote(v string) bool {
+	if v == "" {
+		return true
+	}
+	for _, r := range v {
+		if r <= ' ' || r == '=' || r == '"' || r == '\\' || r == utf8.RuneError || !unicode.IsPrint(r) {
+			return true
+		}
+	}
+	return false
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"os"
 	"sync"
+	"sync/atomic"
 )
 
 var ErrSkipClose = errors.New("skip running closing")
@@ -15,6 +16,7 @@ var ErrSkipClose = errors.New("skip running closing")
 // to handle log messages
 type LogHandler interface {
 	Handle(loggerName string, msg *LogMessage) // handles the log message
+	Flush(ctx context.Context) error           // waits for queued messages to be written
 
 	Start() error    // starts the handler
 	Close() error    // closes the handler
@@ -22,6 +24,8 @@ type LogHandler interface {
 }
 
 // Start() -> StartFunc() -> Subprocess -> CancelPreFunc() -> CancelPostFunc() -> OnSigint() -> CloseFunc()
+//
+// Flush() -> FlushFunc(), ran by the handler goroutine once the queue before it is handled
 type BaseHandler struct {
 	LogHandler
 
@@ -31,8 +35,19 @@ type BaseHandler struct {
 	cancel context.CancelFunc
 	logCh  chan *LogMessage
 
-	running   bool
-	cleanupId uint64
+	running         bool
+	cleanupId       uint64
+	cleanupPriority int    // see setCleanup
+	cleanupSet      bool   // cleanupPriority was set, otherwise CleanupPriorityHandler is used
+	defaultName     string // used without a Name
+	formatter       atomic.Pointer[Formatter]
+	level           atomic.Int32 // minimum level, messages below it are never queued
+
+	queueSize    int // capacity of logCh, defaults to 1024
+	backpressure Backpressure
+	dropped      atomic.Uint64
+
+	Name string // used in shutdown reports, defaults to "log handler"
 
 	HandleFunc func(context.Context, *LogMessage) error
 
@@ -40,7 +55,8 @@ type BaseHandler struct {
 	CancelPreFunc  func(context.Context, LogHandler) error // runs before ctx.cancel is executed, return ErrSkipClose to skip
 	CancelPostFunc func(context.Context, LogHandler) error
 	CloseFunc      func(context.Context, LogHandler) error
-	Subprocesses   []func(context.Context) error // processes must terminate when ctx is done
+	FlushFunc      func(context.Context, LogHandler) error // syncs the underlying writer
+	Subprocesses   []func(context.Context) error           // processes must terminate when ctx is done
 }
 
 func (b *BaseHandler) IsRunning() bool {
@@ -49,6 +65,40 @@ func (b *BaseHandler) IsRunning() bool {
 	return b.running
 }
 
+// SetFormatter changes how messages are laid out,
+// a nil Formatter restores DefaultFormatter
+func (b *BaseHandler) SetFormatter(f Formatter) {
+	if f == nil {
+		b.formatter.Store(nil)
+		return
+	}
+	b.formatter.Store(&f)
+}
+
+func (b *BaseHandler) GetFormatter() Formatter {
+	if f := b.formatter.Load(); f != nil {
+		return *f
+	}
+	return DefaultFormatter
+}
+
+// Format lays out msg with the handler's Formatter
+func (b *BaseHandler) Format(msg *LogMessage) ([]byte, error) {
+	return b.GetFormatter().Format(msg)
+}
+
+// SetLevel sets the minimum level of messages the handler accepts,
+// defaults to TRACE
+func (b *BaseHandler) SetLevel(level Level) error {
+	if !level.IsValid() {
+		return ErrInvalidLogLevel
+	}
+	b.level.Store(int32(level))
+	return nil
+}
+
+func (b *BaseHandler) GetLevel() Level { return Level(b.level.Load()) }
+
 func (b *BaseHandler) Start() error {
 	b.mu.Lock()
 	if b.running {
@@ -61,8 +111,16 @@ func (b *BaseHandler) Start() error {
 		return ErrInvalidLogHandler
 	}
 
+	if !b.cleanupSet {
+		b.cleanupPriority = CleanupPriorityHandler
+	}
+
 	b.ctx, b.cancel = context.WithCancel(context.Background())
-	b.logCh = make(chan *LogMessage, 1<<10)
+	queueSize := b.queueSize
+	if queueSize == 0 {
+		queueSize = defaultQueueSize
+	}
+	b.logCh = make(chan *LogMessage, queueSize)
 	b.running = true
 	b.wg.Add(len(b.Subprocesses) + 1)
 
@@ -73,15 +131,14 @@ func (b *BaseHandler) Start() error {
 		}
 	}
 
-	b.cleanupId = registerCleanup(func() error {
-		b.mu.Lock()
-		defer b.mu.Unlock()
-
-		var err error
-		if b.CancelPreFunc != nil {
-			err = b.CancelPreFunc(b.ctx, b)
-		}
-		return errors.Join(err, b.close())
+	b.cleanupId = registerCleanup(Cleanup{
+		Name:     b.cleanupName(),
+		Priority: b.cleanupPriority,
+		Fn: func(context.Context) error {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			return b.close(true)
+		},
 	})
 	b.mu.Unlock()
 
@@ -112,7 +169,34 @@ func (b *BaseHandler) Start() error {
 }
 
 // callers responsiblity to hold a lock
-func (b *BaseHandler) close() error {
+func (b *BaseHandler) cleanupName() string {
+	switch {
+	case b.Name != "":
+		return b.Name
+	case b.defaultName != "":
+		return b.defaultName
+	default:
+		return "log handler"
+	}
+}
+
+// setCleanup changes the priority the handler shuts down with,
+// name is only used if the handler has no Name
+func (b *BaseHandler) setCleanup(name string, priority int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.defaultName = name
+	b.cleanupPriority, b.cleanupSet = priority, true
+	if b.cleanupId != 0 {
+		updateCleanup(b.cleanupId, b.cleanupName(), priority)
+	}
+}
+
+// callers responsiblity to hold a lock
+//
+// force closes the handler even if CancelPreFunc returns ErrSkipClose
+func (b *BaseHandler) close(force bool) error {
 	if !b.running {
 		return ErrNotStarted
 	}
@@ -121,7 +205,10 @@ func (b *BaseHandler) close() error {
 	if b.CancelPreFunc != nil {
 		err := b.CancelPreFunc(b.ctx, b)
 		if err != nil && errors.Is(err, ErrSkipClose) {
-			return nil
+			if !force {
+				return nil
+			}
+			err = nil
 		}
 		errs = append(errs, err)
 	}
@@ -144,7 +231,7 @@ func (b *BaseHandler) close() error {
 		errs = append(errs, b.CloseFunc(b.ctx, b))
 	}
 
-	close(b.logCh)
+	// logCh is left open, senders may still be waiting on it
 	if len(errs) > 0 {
 		return errors.Join(errs...)
 	}
@@ -154,27 +241,67 @@ func (b *BaseHandler) close() error {
 func (b *BaseHandler) Close() error {
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	return b.close()
+	return b.close(false)
 }
 
 func (b *BaseHandler) Handle(loggerName string, msg *LogMessage) {
-	if msg == nil {
+	if msg == nil || msg.Level < b.GetLevel() {
 		return
 	}
 
 	b.mu.RLock()
-	running := b.running
+	running, bp, ctx, logCh := b.running, b.backpressure, b.ctx, b.logCh
 	b.mu.RUnlock()
 	if !running {
 		return
 	}
 
+	b.enqueue(bp, ctx.Done(), logCh, acquireLogMessage(loggerName, msg))
+}
+
+// Flush blocks until everything queued before the call has been handled
+// and FlushFunc has ran, the handler keeps running afterwards
+func (b *BaseHandler) Flush(ctx context.Context) error {
+	b.mu.RLock()
+	running, handlerCtx, logCh := b.running, b.ctx, b.logCh
+	b.mu.RUnlock()
+	if !running {
+		return ErrNotStarted
+	}
+
+	done := make(chan error, 1)
 	select {
-	case <-b.ctx.Done():
-		return
-	case b.logCh <- acquireLogMessage(loggerName, msg):
-	default: // drop
+	case logCh <- &LogMessage{flushed: done}:
+	case <-handlerCtx.Done():
+		return ErrNotStarted
+	case <-ctx.Done():
+		return ctx.Err()
 	}
+
+	select {
+	case err := <-done:
+		return err
+	case <-handlerCtx.Done():
+		b.wg.Wait() // the marker is handled while draining
+		select {
+		case err := <-done:
+			return err
+		default:
+			return ErrNotStarted
+		}
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *BaseHandler) handleFlush(ctx context.Context, m *LogMessage) {
+	var err error
+	if b.FlushFunc != nil {
+		err = noPanicRun("flush-log-handler", func() error {
+			return b.FlushFunc(ctx, b)
+		})
+	}
+	m.flushed <- err
 }
 
 func (b *BaseHandler) logHandler(ready chan struct{}) {
@@ -185,6 +312,10 @@ func (b *BaseHandler) logHandler(ready chan struct{}) {
 			for {
 				select {
 				case m := <-b.logCh:
+					if m.flushed != nil {
+						b.handleFlush(context.Background(), m)
+						continue
+					}
 					_ = noPanicRun("flush-log-msg", func() error {
 						return b.HandleFunc(context.Background(), m)
 					})
@@ -195,6 +326,10 @@ func (b *BaseHandler) logHandler(ready chan struct{}) {
 			}
 
 		case m := <-b.logCh:
+			if m.flushed != nil {
+				b.handleFlush(b.ctx, m)
+				continue
+			}
 			err := noPanicRun("write-log-msg", func() error {
 				return b.HandleFunc(b.ctx, m)
 			})
@@ -215,9 +350,25 @@ func NewWriterHandler(writer io.Writer) *WriterHandler {
 	wr := &WriterHandler{writer: writer}
 
 	wr.BaseHandler = BaseHandler{
-		HandleFunc: func(ctx context.Context, msg *LogMessage) (err error) {
-			_, err = fmt.Fprint(wr.writer, msg.String(""))
-			return
+		defaultName: "writer handler",
+		HandleFunc: func(ctx context.Context, msg *LogMessage) error {
+			b, err := wr.Format(msg)
+			if err != nil {
+				return err
+			}
+			_, err = wr.writer.Write(b)
+			return err
+		},
+		FlushFunc: func(ctx context.Context, h LogHandler) error {
+			switch w := wr.writer.(type) {
+			case interface{ Flush() error }:
+				return w.Flush()
+			case interface{ Sync() error }:
+				if w != os.Stdout && w != os.Stderr { // syncing a terminal or pipe fails
+					return w.Sync()
+				}
+			}
+			return nil
 		},
 		CloseFunc: func(ctx context.Context, h LogHandler) error {
 			if wr.writer != os.Stdout && wr.writer != os.Stderr {
@@ -232,6 +383,13 @@ func NewWriterHandler(writer io.Writer) *WriterHandler {
 	return wr
 }
 
+// NewWriterHandlerWithFormatter creates a WriterHandler using f
+func NewWriterHandlerWithFormatter(writer io.Writer, f Formatter) *WriterHandler {
+	wr := NewWriterHandler(writer)
+	wr.SetFormatter(f)
+	return wr
+}
+
 func (wh *WriterHandler) Writer() io.Writer {
 	wh.mu.RLock()
 	defer wh.mu.RUnlock()
@@ -0,0 +1,65 @@
+package log
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseLevel parses a level name (case-insensitive) or its number
+//
+//	ParseLevel("warn")    // WARN
+//	ParseLevel("Warning") // WARN
+//	ParseLevel("3")       // WARN
+func ParseLevel(s string) (Level, error) {
+	s = strings.TrimSpace(s)
+
+	if n, err := strconv.Atoi(s); err == nil {
+		if l := Level(n); l.IsValid() {
+			return l, nil
+		}
+		return 0, fmt.Errorf("%w: %q", ErrInvalidLogLevel, s)
+	}
+
+	if strings.EqualFold(s, "warning") {
+		return WARN, nil
+	}
+	for i, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return Level(i), nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %q", ErrInvalidLogLevel, s)
+}
+
+// IsValid reports whether l is one of the defined levels
+func (l Level) IsValid() bool { return l >= TRACE && l <= QUIET }
+
+func (l Level) String() string {
+	if l.IsValid() {
+		return levelNames[l]
+	}
+	return "Level(" + strconv.Itoa(int(l)) + ")"
+}
+
+func (l Level) MarshalText() ([]byte, error) {
+	if !l.IsValid() {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidLogLevel, int(l))
+	}
+	return []byte(levelNames[l]), nil
+}
+
+func (l *Level) UnmarshalText(text []byte) error {
+	parsed, err := ParseLevel(string(text))
+	if err != nil {
+		return err
+	}
+	*l = parsed
+	return nil
+}
+
+// Set implements flag.Value
+//
+//	level := log.INFO
+//	flag.Var(&level, "level", "log level")
+func (l *Level) Set(s string) error { return l.UnmarshalText([]byte(s)) }
@@ -0,0 +1,54 @@
+package log_test
+
+import (
+	"encoding/json"
+	"flag"
+	"testing"
+
+	"github.com/lattesec/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLevel(t *testing.T) {
+	for in, want := range map[string]log.Level{
+		"trace":   log.TRACE,
+		"DEBUG":   log.DEBUG,
+		" Info ":  log.INFO,
+		"warning": log.WARN,
+		"4":       log.ERROR,
+		"panic":   log.PANIC,
+		"Fatal":   log.FATAL,
+		"quiet":   log.QUIET,
+	} {
+		got, err := log.ParseLevel(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+
+	for _, in := range []string{"", "loud", "-1", "42"} {
+		_, err := log.ParseLevel(in)
+		assert.ErrorIs(t, err, log.ErrInvalidLogLevel, in)
+	}
+}
+
+func TestLevelEncoding(t *testing.T) {
+	var cfg struct {
+		Level log.Level `json:"level"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(`{"level":"warn"}`), &cfg))
+	assert.Equal(t, log.WARN, cfg.Level)
+
+	b, err := json.Marshal(cfg)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"level":"WARN"}`, string(b))
+
+	level := log.INFO
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Var(&level, "level", "log level")
+	require.NoError(t, fs.Parse([]string{"-level", "debug"}))
+	assert.Equal(t, log.DEBUG, level)
+
+	assert.Equal(t, "Level(42)", log.Level(42).String())
+	assert.Equal(t, "Level(-1)", (&log.LogMessage{Level: -1}).LevelString(), "expected out of range levels not to panic")
+}
@@ -1,6 +1,7 @@
 package log
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -19,11 +20,13 @@ const (
 	INFO
 	WARN
 	ERROR
+	PANIC // logs, flushes and panics
+	FATAL // logs, cleans up and exits
 	QUIET
 )
 
 var (
-	levelNames = [6]string{"TRACE", "DEBUG", "INFO", "WARN", "ERROR", "QUIET"}
+	levelNames = [...]string{"TRACE", "DEBUG", "INFO", "WARN", "ERROR", "PANIC", "FATAL", "QUIET"}
 
 	defaultLogger        atomic.Pointer[Logger]
 	DefaultStdoutHandler atomic.Pointer[WriterHandler]
@@ -31,17 +34,21 @@ var (
 
 	ErrNotStarted                = errors.New("not started")
 	ErrAlreadyStarted            = errors.New("already started")
+	ErrDiskGuardUnsupported      = errors.New("disk guard needs a Probe on this platform")
+	ErrFlushDropped              = errors.New("flush dropped from a full queue")
+	ErrFormatterConflict         = errors.New("log file is open with a different formatter")
 	ErrInvalidLogHandler         = errors.New("invalid log handler")
 	ErrInvalidLogLevel           = errors.New("invalid log level")
 	ErrInvalidMaxFileSize        = errors.New("invalid max file size")
+	ErrInvalidPathTemplate       = errors.New("invalid path template")
+	ErrInvalidQueueSize          = errors.New("invalid queue size")
+	ErrInvalidSchedule           = errors.New("invalid schedule")
 	ErrMissingLogFilename        = errors.New("missing log filename")
 	ErrNoLogFileConfigured       = errors.New("no log file configured")
 	ErrFoundDirWhenExpectingFile = errors.New("found directory when expecting file")
 )
 
 func init() {
-	go handleSigint()
-
 	if err := RegisterStdoutHandler(NewWriterHandler(os.Stdout)); err != nil {
 		panic(err)
 	}
@@ -70,8 +77,15 @@ func DefaultLogger() *Logger {
 	return logger
 }
 
+// Flush waits for the default logger's handlers
+// to write everything queued so far
+func Flush(ctx context.Context) error { return DefaultLogger().Flush(ctx) }
+
+// Deprecated: use Close or Shutdown
 func Sync() {
-	runCleanup()
+	if err := Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "log shutdown failed: %v\n", err)
+	}
 }
 
 func Register(l *Logger) {
@@ -79,6 +93,7 @@ func Register(l *Logger) {
 }
 
 func RegisterStdoutHandler(handler *WriterHandler) error {
+	handler.setCleanup("stdout handler", CleanupPriorityStd)
 	if err := handler.Start(); err != nil && err != ErrAlreadyStarted {
 		return err
 	}
@@ -87,6 +102,7 @@ func RegisterStdoutHandler(handler *WriterHandler) error {
 }
 
 func RegisterStderrHandler(handler *WriterHandler) error {
+	handler.setCleanup("stderr handler", CleanupPriorityStd)
 	if err := handler.Start(); err != nil && err != ErrAlreadyStarted {
 		return err
 	}
@@ -99,4 +115,8 @@ func Debug() *LogMessage          { return DefaultLogger().Debug() }
 func Info() *LogMessage           { return DefaultLogger().Info() }
 func Warn() *LogMessage           { return DefaultLogger().Warn() }
 func Error() *LogMessage          { return DefaultLogger().Error() }
+func Panic() *LogMessage          { return DefaultLogger().Panic() }
 func Fatal() *LogMessage          { return DefaultLogger().Fatal() }
+
+// With returns a child of the default logger with fields bound
+func With(fields ...Field) *Logger { return DefaultLogger().With(fields...) }
@@ -2,8 +2,13 @@ package log_test
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/lattesec/log"
 	"github.com/stretchr/testify/assert"
@@ -114,3 +119,272 @@ func TestClosingOneOfManyFileHandlersStillWorks(t *testing.T) {
 	assert.Contains(t, content, "from h2")
 	assert.Contains(t, content, "still from h2")
 }
+
+func TestWriterHandlerUsesFormatter(t *testing.T) {
+	var plain, custom bytes.Buffer
+
+	l, err := log.NewLogger().
+		WithLevel(log.INFO).
+		WithStderr(false).
+		WithStdout(false).
+		WithHandlers(log.NewWriterHandler(&plain)).
+		WithWriter(&custom).
+		WithFormatter(log.FormatterFunc(func(msg *log.LogMessage) ([]byte, error) {
+			return []byte(msg.LoggerName() + "|" + msg.Message + "\n"), nil
+		})).
+		Name("fmt").
+		Build()
+	require.NoError(t, err)
+	require.NoError(t, l.Start(), "failed to start logger")
+
+	l.Info().Msg("formatted").Send()
+
+	require.NoError(t, l.Close())
+	assert.Contains(t, plain.String(), "[INFO] fmt: formatted", "expected default formatter on handlers passed in")
+	assert.Equal(t, "fmt|formatted\n", custom.String(), "expected custom formatter on builder handlers")
+}
+
+func TestSharedFileKeepsFormatter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shared.log")
+	build := func(f log.Formatter) (*log.Logger, error) {
+		return log.NewLogger().
+			WithLevel(log.INFO).
+			WithStderr(false).
+			WithStdout(false).
+			WithFile(path, 0).
+			WithFormatter(f).
+			Build()
+	}
+
+	a, err := build(log.JSONFormatter{})
+	require.NoError(t, err)
+	require.NoError(t, a.Start(), "failed to start logger")
+	defer func() { require.NoError(t, a.Close()) }()
+
+	_, err = build(log.LogfmtFormatter{})
+	require.ErrorIs(t, err, log.ErrFormatterConflict)
+
+	b, err := build(log.JSONFormatter{})
+	require.NoError(t, err, "expected an equal formatter to share the file")
+	require.NoError(t, b.Start(), "failed to start logger")
+	require.NoError(t, b.Close())
+
+	a.Info().Msg("still json").Send()
+	require.NoError(t, a.Flush(context.Background()))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"msg":"still json"`)
+}
+
+func TestJSONFormatter(t *testing.T) {
+	var buf bytes.Buffer
+
+	h := log.NewWriterHandlerWithFormatter(&buf, log.JSONFormatter{})
+	l, err := log.NewLogger().
+		WithLevel(log.INFO).
+		WithStderr(false).
+		WithStdout(false).
+		WithHandlers(h).
+		Name("api").
+		Build()
+	require.NoError(t, err)
+	require.NoError(t, l.Start(), "failed to start logger")
+
+	l.Info().Msg("say \"hi\"\n").WithMeta("path", "/a,b{c}").WithMeta("ctl", "\x01\t").Send()
+
+	require.NoError(t, l.Close())
+
+	var got map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &got), "expected valid JSON, got %q", buf.String())
+	assert.Equal(t, "INFO", got["level"])
+	assert.Equal(t, "api", got["logger"])
+	assert.Equal(t, "say \"hi\"", got["msg"])
+	assert.Equal(t, "/a,b{c}", got["path"])
+	assert.Equal(t, "\x01\t", got["ctl"])
+	assert.NotEmpty(t, got["time"])
+}
+
+func TestLogfmtFormatter(t *testing.T) {
+	msg := &log.LogMessage{
+		Timestamp: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		Level:     log.WARN,
+		Message:   "disk \"full\"",
+	}
+	msg.WithMeta("path", "/var/log").
+		WithMeta("query", "a=b c").
+		WithMeta("bad key", "line1\nline2").
+		WithMeta("empty", "")
+
+	b, err := log.LogfmtFormatter{}.Format(msg)
+	require.NoError(t, err)
+	assert.Equal(t,
+		`ts=2025-01-02T03:04:05Z level=warn logger="" msg="disk \"full\"" path=/var/log query="a=b c" bad_key="line1\nline2" empty=""`+"\n",
+		string(b),
+	)
+}
+
+func TestTypedFields(t *testing.T) {
+	msg := &log.LogMessage{Level: log.INFO, Message: "typed"}
+	msg.WithFields(
+		log.Int("status", 200),
+		log.Bool("ok", true),
+		log.Dur("took", 1500*time.Millisecond),
+		log.Err(errors.New("boom")),
+		log.Group("req", log.String("method", "GET"), log.Float64("ratio", 0.5)),
+	).WithMeta("n", int8(3))
+
+	assert.Equal(t, log.KindInt64, msg.Meta[0].V.Kind())
+	assert.Equal(t, int64(200), msg.Meta[0].V.Int64())
+	assert.Equal(t, log.KindInt64, msg.Meta[5].V.Kind(), "expected WithMeta to keep the type")
+	assert.Contains(t, msg.String("typed"), "{status=200, ok=true, took=1.5s, error=boom, req={method=GET, ratio=0.5}, n=3}")
+
+	b, err := log.JSONFormatter{}.Format(msg)
+	require.NoError(t, err)
+
+	var got map[string]any
+	require.NoError(t, json.Unmarshal(b, &got), "expected valid JSON, got %q", string(b))
+	assert.Equal(t, float64(200), got["status"])
+	assert.Equal(t, true, got["ok"])
+	assert.Equal(t, "1.5s", got["took"])
+	assert.Equal(t, "boom", got["error"])
+	assert.Equal(t, map[string]any{"method": "GET", "ratio": 0.5}, got["req"])
+
+	b, err = log.LogfmtFormatter{}.Format(msg)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), "req.method=GET req.ratio=0.5")
+}
+
+func TestNilErrorField(t *testing.T) {
+	msg := &log.LogMessage{Level: log.INFO, Message: "nil"}
+	msg.WithFields(log.Err(nil))
+	assert.Equal(t, log.KindError, msg.Meta[0].V.Kind())
+
+	for _, f := range []log.Formatter{log.TextFormatter{}, log.JSONFormatter{}, log.LogfmtFormatter{}} {
+		b, err := f.Format(msg)
+		require.NoError(t, err)
+		assert.Contains(t, string(b), "<nil>", "%T", f)
+	}
+}
+
+func TestChildLoggerBindsFields(t *testing.T) {
+	var buf bytes.Buffer
+
+	l, err := log.NewLogger().
+		WithLevel(log.INFO).
+		WithStderr(false).
+		WithStdout(false).
+		WithHandlers(log.NewWriterHandler(&buf)).
+		Build()
+	require.NoError(t, err)
+	require.NoError(t, l.Start(), "failed to start logger")
+
+	req := l.With(log.String("request_id", "abc"))
+	sub := req.With(log.Int("attempt", 2))
+
+	req.Info().Msg("from child").WithMeta("k", "v").Send()
+	sub.Debug().Msg("filtered by shared level").Send()
+	require.NoError(t, l.SetLevel(log.DEBUG))
+	sub.Debug().Msg("from grandchild").Send()
+	l.Info().Msg("from parent").Send()
+
+	assert.True(t, sub.IsRunning(), "expected child to share lifecycle")
+	require.NoError(t, req.Close())
+	assert.False(t, l.IsRunning(), "expected closing child to close parent")
+
+	got := buf.String()
+	assert.Contains(t, got, "from child {request_id=abc, k=v}")
+	assert.Contains(t, got, "from grandchild {request_id=abc, attempt=2}")
+	assert.Contains(t, got, "from parent\n")
+	assert.NotContains(t, got, "filtered by shared level")
+}
+
+func TestZeroLogger(t *testing.T) {
+	var l log.Logger
+	assert.NotPanics(t, func() {
+		l.Info().Msg("nowhere to go").Send()
+		l.With(log.String("k", "v")).Error().Msg("still nowhere").Send()
+	})
+
+	require.NoError(t, l.Start())
+	assert.True(t, l.With().IsRunning(), "expected children of the zero logger to share its state")
+	require.NoError(t, l.Close())
+}
+
+func TestPanicFlushesBeforePanicking(t *testing.T) {
+	var buf bytes.Buffer
+
+	l, err := log.NewLogger().
+		WithLevel(log.INFO).
+		WithStderr(false).
+		WithStdout(false).
+		WithHandlers(log.NewWriterHandler(&buf)).
+		Build()
+	require.NoError(t, err)
+	require.NoError(t, l.Start(), "failed to start logger")
+
+	assert.PanicsWithValue(t, "going down", func() {
+		l.Panic().Msg("going down").Send()
+	})
+	assert.Contains(t, buf.String(), "[PANIC] ???: going down", "expected message to be written before panicking")
+
+	require.NoError(t, l.Close())
+}
+
+func TestPerHandlerLevel(t *testing.T) {
+	var verbose, quiet bytes.Buffer
+
+	quietHandler := log.NewWriterHandler(&quiet)
+	require.NoError(t, quietHandler.SetLevel(log.WARN))
+	assert.ErrorIs(t, quietHandler.SetLevel(log.Level(42)), log.ErrInvalidLogLevel)
+
+	l, err := log.NewLogger().
+		WithLevel(log.DEBUG).
+		WithStderr(false).
+		WithStdout(false).
+		WithHandlers(log.NewWriterHandler(&verbose), quietHandler).
+		Build()
+	require.NoError(t, err)
+	require.NoError(t, l.Start(), "failed to start logger")
+
+	l.Debug().Msg("debug msg").Send()
+	l.Warn().Msg("warn msg").Send()
+
+	require.NoError(t, l.Close())
+	assert.Contains(t, verbose.String(), "debug msg")
+	assert.Contains(t, verbose.String(), "warn msg")
+	assert.NotContains(t, quiet.String(), "debug msg", "expected handler level to filter")
+	assert.Contains(t, quiet.String(), "warn msg")
+}
+
+func TestFlushKeepsHandlersRunning(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "logtest-*.log")
+	require.NoError(t, err)
+	defer func() { _ = os.Remove(tmpfile.Name()) }()
+
+	require.NoError(t, tmpfile.Close())
+
+	l, err := log.NewLogger().
+		WithLevel(log.INFO).
+		WithStderr(false).
+		WithStdout(false).
+		WithFile(tmpfile.Name(), 0).
+		Build()
+	require.NoError(t, err)
+	require.NoError(t, l.Start(), "failed to start logger")
+
+	for i := range 100 {
+		l.Info().Msgf("before flush %d", i).Send()
+	}
+	require.NoError(t, l.Flush(context.Background()))
+
+	data, err := os.ReadFile(tmpfile.Name())
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "before flush 99", "expected queued messages to be written by Flush")
+
+	l.Info().Msg("after flush").Send()
+	require.NoError(t, l.Close())
+
+	data, err = os.ReadFile(tmpfile.Name())
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "after flush", "expected handlers to keep running after Flush")
+}
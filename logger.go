@@ -1,11 +1,18 @@
 package log
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"os"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// how long Panic waits for handlers before panicking
+const panicFlushTimeout = 5 * time.Second
+
 type ILogger interface {
 	Start() error
 	Close() error
@@ -21,31 +28,91 @@ type ILogger interface {
 	Stderr(on bool)
 
 	SendLog(msg *LogMessage)
+	Flush(ctx context.Context) error
 
 	Log() *LogMessage
 	Debug() *LogMessage
 	Info() *LogMessage
 	Warn() *LogMessage
 	Error() *LogMessage
+	Panic() *LogMessage
 	Fatal() *LogMessage
 }
 
+// Logger sends messages to its handlers
+//
+// The zero Logger is ready to use, it has no handlers,
+// logs at TRACE and leaves stdout and stderr alone until enabled
 type Logger struct {
+	state  atomic.Pointer[loggerCore] // shared with children, see core
+	fields []Field                    // bound fields, prepended to every message
+}
+
+// state shared between a logger and its children
+type loggerCore struct {
 	LoggerMeta
-	mu      sync.RWMutex
-	running bool
+	mu         sync.RWMutex
+	running    bool
+	cleanupIds []uint64 // entries in the cleanup registry while running
+}
+
+// With returns a child logger that prepends fields to every message
+//
+// The child shares the handlers, level and lifecycle of its parent,
+// closing either closes both
+func (l *Logger) With(fields ...Field) *Logger {
+	bound := make([]Field, 0, len(l.fields)+len(fields))
+	bound = append(bound, l.fields...)
+	bound = append(bound, fields...)
+	child := &Logger{fields: bound}
+	child.state.Store(l.core())
+	return child
+}
+
+func newLogger(core *loggerCore) *Logger {
+	l := &Logger{}
+	l.state.Store(core)
+	return l
+}
+
+// core returns the state of the logger, the zero Logger gets its own on first use
+func (l *Logger) core() *loggerCore {
+	if c := l.state.Load(); c != nil {
+		return c
+	}
+	l.state.CompareAndSwap(nil, &loggerCore{})
+	return l.state.Load()
 }
 
 func (l *Logger) Start() error {
-	l.mu.Lock()
-	defer l.mu.Unlock()
+	c := l.core()
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	if l.running {
+	if c.running {
 		return ErrAlreadyStarted
 	}
 
-	l.running = true
-	for _, h := range l.handlers {
+	c.running = true
+	registerLogger(c)
+
+	for i, fn := range c.cleanup {
+		c.cleanupIds = append(c.cleanupIds, registerCleanup(Cleanup{
+			Name:     fmt.Sprintf("logger %q cleanup #%d", c.name, i),
+			Priority: CleanupPriorityUser,
+			Fn: func(context.Context) error {
+				fn()
+				return nil
+			},
+		}))
+	}
+	c.cleanupIds = append(c.cleanupIds, registerCleanup(Cleanup{
+		Name:     fmt.Sprintf("logger %q", c.name),
+		Priority: CleanupPriorityLogger,
+		Fn:       func(context.Context) error { return l.Close() },
+	}))
+
+	for _, h := range c.handlers {
 		if err := h.Start(); err != nil && err != ErrAlreadyStarted {
 			return err
 		}
@@ -54,16 +121,23 @@ func (l *Logger) Start() error {
 }
 
 func (l *Logger) Close() error {
-	l.mu.Lock()
-	defer l.mu.Unlock()
+	c := l.core()
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	if !l.running {
+	if !c.running {
 		return nil
 	}
 
-	l.running = false
+	c.running = false
+	unregisterLogger(c)
+	for _, id := range c.cleanupIds {
+		unregisterCleanup(id)
+	}
+	c.cleanupIds = nil
+
 	errs := []error{}
-	for _, h := range l.handlers {
+	for _, h := range c.handlers {
 		if err := h.Close(); err != nil {
 			errs = append(errs, err)
 		}
@@ -72,32 +146,98 @@ func (l *Logger) Close() error {
 	return errors.Join(errs...)
 }
 
-func (l *Logger) GetName() string     { l.mu.RLock(); defer l.mu.RUnlock(); return l.name }
-func (l *Logger) SetName(name string) { l.mu.Lock(); defer l.mu.Unlock(); l.name = name }
+func (l *Logger) GetName() string {
+	c := l.core()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.name
+}
+
+func (l *Logger) SetName(name string) {
+	c := l.core()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.name = name
+}
+
+func (l *Logger) GetLevel() Level {
+	c := l.core()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.level
+}
 
-func (l *Logger) GetLevel() Level { l.mu.RLock(); defer l.mu.RUnlock(); return l.level }
 func (l *Logger) SetLevel(level Level) error {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-	if level < TRACE || level > QUIET {
+	c := l.core()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !level.IsValid() {
 		return ErrInvalidLogLevel
 	}
-	l.level = level
+	c.level = level
 	return nil
 }
 
-func (l *Logger) IsRunning() bool { l.mu.RLock(); defer l.mu.RUnlock(); return l.running }
-func (l *Logger) Stdout(on bool)  { l.mu.Lock(); defer l.mu.Unlock(); l.stdoutEnabled = on }
-func (l *Logger) Stderr(on bool)  { l.mu.Lock(); defer l.mu.Unlock(); l.stderrEnabled = on }
+func (l *Logger) IsRunning() bool {
+	c := l.core()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.running
+}
+
+func (l *Logger) Stdout(on bool) {
+	c := l.core()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stdoutEnabled = on
+}
+
+func (l *Logger) Stderr(on bool) {
+	c := l.core()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stderrEnabled = on
+}
+
+// Flush waits for the handlers, including the default stdout and stderr handlers,
+// to write and sync everything queued so far
+//
+// Unlike Close, the handlers keep running
+func (l *Logger) Flush(ctx context.Context) error {
+	var handlers []LogHandler
+	if h := DefaultStdoutHandler.Load(); h != nil {
+		handlers = append(handlers, h)
+	}
+	if h := DefaultStderrHandler.Load(); h != nil {
+		handlers = append(handlers, h)
+	}
 
-func (l *Logger) SendLog(msg *LogMessage) {
-	l.mu.RLock()
-	if msg.Level < l.level {
-		l.mu.RUnlock()
+	c := l.core()
+	c.mu.RLock()
+	handlers = append(handlers, c.handlers...)
+	c.mu.RUnlock()
+
+	errs := []error{}
+	for _, h := range handlers {
+		if err := h.Flush(ctx); err != nil && !errors.Is(err, ErrNotStarted) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (l *Logger) SendLog(msg *LogMessage) { l.send(msg, nil) }
+
+// send hands msg to the handlers, sent is called with each of them
+func (l *Logger) send(msg *LogMessage, sent func(LogHandler)) {
+	c := l.core()
+	c.mu.RLock()
+	if msg.Level < c.level {
+		c.mu.RUnlock()
 		return
 	}
 
-	if (l.level == TRACE && msg.Level >= ERROR) || msg.Level == TRACE {
+	if c.wantsDebugInfo(msg.Level) {
 		if msg.trace == "" {
 			msg.WithTraceStack()
 		}
@@ -106,23 +246,45 @@ func (l *Logger) SendLog(msg *LogMessage) {
 		}
 	}
 
-	shouldWriteToStd := l.level != QUIET
-	name := l.name
-	l.mu.RUnlock()
+	if len(l.fields) > 0 {
+		meta := make([]Field, 0, len(l.fields)+len(msg.Meta))
+		meta = append(meta, l.fields...)
+		msg.Meta = append(meta, msg.Meta...)
+	}
+
+	shouldWriteToStd := c.level != QUIET
+	name := c.name
+	c.mu.RUnlock()
 
+	var std LogHandler
 	if shouldWriteToStd {
-		if msg.Level >= WARN && l.stderrEnabled {
-			DefaultStderrHandler.Load().Handle(name, msg)
-		} else if l.stdoutEnabled {
-			DefaultStdoutHandler.Load().Handle(name, msg)
+		if msg.Level >= WARN && c.stderrEnabled {
+			std = DefaultStderrHandler.Load()
+		} else if c.stdoutEnabled {
+			std = DefaultStdoutHandler.Load()
+		}
+	}
+	if std != nil {
+		std.Handle(name, msg)
+		if sent != nil {
+			sent(std)
 		}
 	}
 
-	for _, h := range l.handlers {
+	for _, h := range c.handlers {
 		h.Handle(name, msg)
+		if sent != nil {
+			sent(h)
+		}
 	}
 }
 
+// whether messages at level carry a caller and stack trace,
+// callers responsibility to hold a lock
+func (c *loggerCore) wantsDebugInfo(level Level) bool {
+	return (c.level == TRACE && level >= ERROR) || level == TRACE
+}
+
 func (l *Logger) Log(level Level) *LogMessage {
 	lm := NewLogMessage().WithSend(l.SendLog)
 	lm.Level = level
@@ -132,19 +294,33 @@ func (l *Logger) Debug() *LogMessage { return NewLogMessage().Debug().WithSend(l
 func (l *Logger) Info() *LogMessage  { return NewLogMessage().Info().WithSend(l.SendLog) }
 func (l *Logger) Warn() *LogMessage  { return NewLogMessage().Warn().WithSend(l.SendLog) }
 func (l *Logger) Error() *LogMessage { return NewLogMessage().Error().WithSend(l.SendLog) }
+
+// Panic logs the message, waits for the handlers it went to and panics with the message
+func (l *Logger) Panic() *LogMessage {
+	return NewLogMessage().Panic().WithSend(func(lm *LogMessage) {
+		var sent []LogHandler
+		l.send(lm, func(h LogHandler) { sent = append(sent, h) })
+
+		ctx, cancel := context.WithTimeout(context.Background(), panicFlushTimeout)
+		for _, h := range sent {
+			_ = h.Flush(ctx)
+		}
+		cancel()
+
+		panic(lm.Message)
+	})
+}
+
 func (l *Logger) Fatal() *LogMessage {
 	return NewLogMessage().Fatal().WithSend(func(lm *LogMessage) {
 		l.SendLog(lm)
 
-		l.mu.RLock()
-		if l.cleanup != nil {
-			for _, cleanup := range l.cleanup {
-				cleanup()
-			}
+		// runs the logger's cleanups before closing it
+		ctx, cancel := context.WithTimeout(context.Background(), fatalShutdownTimeout)
+		if err := Shutdown(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "log shutdown failed: %v\n", err)
 		}
-		l.mu.RUnlock()
-
-		runCleanup()
+		cancel()
 		os.Exit(1)
 	})
 }
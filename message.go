@@ -4,26 +4,24 @@ import (
 	"fmt"
 	"path/filepath"
 	"runtime"
-	"strings"
 	"time"
 )
 
-type LogMessageMetaKV struct {
-	K, V string
-}
-
 type LogMessage struct {
-	Timestamp time.Time          // timestamp
-	Level     Level              // log level
-	Message   string             // log message
-	Meta      []LogMessageMetaKV // log metadata
+	Timestamp time.Time // timestamp
+	Level     Level     // log level
+	Message   string    // log message
+	Meta      []Field   // log metadata
 
-	trace  string // stack trace (optional)
-	caller string // caller (optional)
+	trace  string  // stack trace (optional)
+	caller string  // caller (optional)
+	pc     uintptr // program counter of the caller (optional)
 
 	loggerName string // used only in log handlers, meaningless otherwise
 
 	send func(*LogMessage)
+
+	flushed chan error // set on flush markers queued by BaseHandler.Flush
 }
 
 // NewLogMessage
@@ -32,7 +30,7 @@ type LogMessage struct {
 func NewLogMessage() *LogMessage {
 	return &LogMessage{
 		Timestamp: time.Now().UTC(),
-		Meta:      make([]LogMessageMetaKV, 0, 1),
+		Meta:      make([]Field, 0, 1),
 	}
 }
 
@@ -51,13 +49,22 @@ func (lm *LogMessage) SendE() error {
 	return nil
 }
 
+// WithMeta adds value under key, keeping its type where possible
 func (lm *LogMessage) WithMeta(key string, value any) *LogMessage {
-	lm.Meta = append(lm.Meta, LogMessageMetaKV{K: key, V: fmt.Sprintf("%v", value)})
+	lm.Meta = append(lm.Meta, Any(key, value))
 	return lm
 }
 
 func (lm *LogMessage) WithMetaf(key, format string, v ...any) *LogMessage {
-	lm.Meta = append(lm.Meta, LogMessageMetaKV{K: key, V: fmt.Sprintf(format, v...)})
+	lm.Meta = append(lm.Meta, String(key, fmt.Sprintf(format, v...)))
+	return lm
+}
+
+// WithFields adds typed fields
+//
+//	lm.WithFields(log.Int("status", 200), log.Dur("took", d))
+func (lm *LogMessage) WithFields(fields ...Field) *LogMessage {
+	lm.Meta = append(lm.Meta, fields...)
 	return lm
 }
 
@@ -67,12 +74,13 @@ func (lm *LogMessage) WithTraceStack() *LogMessage {
 }
 
 func (lm *LogMessage) WithCaller() *LogMessage {
-	lm.caller = traceCaller()
+	lm.pc = tracePC()
+	lm.caller = callerFromPC(lm.pc)
 	return lm
 }
 
 func (lm *LogMessage) WithLevel(level Level) *LogMessage { lm.Level = level; return lm }
-func (lm *LogMessage) LevelString() string               { return levelNames[lm.Level] }
+func (lm *LogMessage) LevelString() string               { return lm.Level.String() }
 
 func (lm *LogMessage) Msg(msg ...any) *LogMessage { lm.Message = fmt.Sprint(msg...); return lm }
 func (lm *LogMessage) Msgf(format string, v ...any) *LogMessage {
@@ -84,45 +92,37 @@ func (lm *LogMessage) Debug() *LogMessage { return lm.WithLevel(DEBUG) }
 func (lm *LogMessage) Info() *LogMessage  { return lm.WithLevel(INFO) }
 func (lm *LogMessage) Warn() *LogMessage  { return lm.WithLevel(WARN) }
 func (lm *LogMessage) Error() *LogMessage { return lm.WithLevel(ERROR) }
-func (lm *LogMessage) Fatal() *LogMessage { return lm.WithLevel(ERROR) }
+func (lm *LogMessage) Panic() *LogMessage { return lm.WithLevel(PANIC) }
+func (lm *LogMessage) Fatal() *LogMessage { return lm.WithLevel(FATAL) }
 
+// String formats the message with the default text layout
 func (lm *LogMessage) String(loggerName string) string {
-	var metaStr string
-	if len(lm.Meta) > 0 {
-		meta := make([]string, 0, len(lm.Meta))
-		for _, m := range lm.Meta {
-			meta = append(meta, fmt.Sprintf("%s=%s", m.K, m.V))
-		}
-		metaStr = fmt.Sprintf(" {%s}", strings.Join(meta, ", "))
-	}
-
-	var debugStr string
-	if lm.trace != "" || lm.caller != "" {
-		debugStr = fmt.Sprintf("\n==== DEBUG ====\nCaller: %s\nTrace: %s", lm.caller, lm.trace) + "===== END =====\n\n"
-	}
-
 	if loggerName == "" {
 		loggerName = lm.loggerName
 	}
+	return formatText(loggerName, lm)
+}
 
-	return strings.TrimSuffix(fmt.Sprintf("%s [%s] %s: %s%s%s",
-		lm.Timestamp.Format(time.RFC3339Nano),
-		lm.LevelString(),
-		loggerName,
-		strings.TrimSuffix(lm.Message, "\n"),
-		metaStr,
-		debugStr,
-	), "\n") + "\n"
+func (lm *LogMessage) LoggerName() string { return lm.loggerName }
+func (lm *LogMessage) Caller() string     { return lm.caller }
+func (lm *LogMessage) Trace() string      { return lm.trace }
+func (lm *LogMessage) PC() uintptr        { return lm.pc }
+
+func tracePC() uintptr {
+	var pcs [1]uintptr
+	runtime.Callers(4, pcs[:])
+	return pcs[0]
 }
 
-func traceCaller() string {
-	pc, file, line, ok := runtime.Caller(3)
-	if !ok {
+func callerFromPC(pc uintptr) string {
+	if pc == 0 {
+		return "???"
+	}
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	if frame.File == "" {
 		return "???"
 	}
-	short := filepath.Base(file)
-	fn := runtime.FuncForPC(pc).Name()
-	return fmt.Sprintf("trace: %s:%d (%s)", short, line, fn)
+	return fmt.Sprintf("trace: %s:%d (%s)", filepath.Base(frame.File), frame.Line, frame.Function)
 }
 
 func traceStack() string {
@@ -6,7 +6,7 @@ import "sync"
 var logMsgPool = sync.Pool{
 	New: func() any {
 		return &LogMessage{
-			Meta: make([]LogMessageMetaKV, 0, 1),
+			Meta: make([]Field, 0, 1),
 		}
 	},
 }
@@ -19,6 +19,7 @@ func acquireLogMessage(loggerName string, msg *LogMessage) *LogMessage {
 	lm.Message = msg.Message
 	lm.trace = msg.trace
 	lm.caller = msg.caller
+	lm.pc = msg.pc
 	lm.loggerName = loggerName
 
 	lm.Meta = lm.Meta[:0]
@@ -28,14 +29,16 @@ func acquireLogMessage(loggerName string, msg *LogMessage) *LogMessage {
 }
 
 func releaseLogMessage(lm *LogMessage) {
+	clear(lm.Meta) // drop references held by values
 	lm.Meta = lm.Meta[:0]
 	lm.trace = ""
 	lm.caller = ""
+	lm.pc = 0
 	lm.loggerName = ""
 
 	// shrink if overinflated
 	if cap(lm.Meta) > 16 {
-		lm.Meta = make([]LogMessageMetaKV, 0, 1)
+		lm.Meta = make([]Field, 0, 1)
 	}
 
 	logMsgPool.Put(lm)
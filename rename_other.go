@@ -0,0 +1,12 @@
+//go:build !windows
+
+package log
+
+import "os"
+
+// renameOpenLog moves the log while filePtr stays open
+//
+// callers responsibility to hold muFile
+func (f *FileHandler) renameOpenLog(from, to string) error {
+	return os.Rename(from, to)
+}
@@ -0,0 +1,32 @@
+//go:build windows
+
+package log
+
+import (
+	"errors"
+	"os"
+)
+
+// renameOpenLog moves the log, windows refuses to rename files that are open
+// so filePtr is closed first and reopened wherever the log ended up
+//
+// callers responsibility to hold muFile
+func (f *FileHandler) renameOpenLog(from, to string) error {
+	_ = f.filePtr.Close()
+
+	err := os.Rename(from, to)
+	at := to
+	if err != nil {
+		at = from
+	}
+
+	reopened, oerr := os.OpenFile(at, os.O_APPEND|os.O_WRONLY, f.fileMode)
+	if oerr != nil {
+		if err == nil {
+			return nil // moved, the caller opens a fresh log in its place
+		}
+		return errors.Join(err, oerr)
+	}
+	f.filePtr = reopened
+	return err
+}
@@ -0,0 +1,67 @@
+package log
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"time"
+)
+
+// Retention limits the rotated archives kept next to a log,
+// zero values mean no limit
+type Retention struct {
+	MaxArchives int           // number of archives to keep
+	MaxAge      time.Duration // archives older than this are removed
+	MaxBytes    int64         // total size of the archives
+}
+
+func (r Retention) isZero() bool { return r == Retention{} }
+
+// WithRetention prunes rotated archives after every rotation and on start
+//
+// Only archives matching the handler's own naming are ever removed
+func WithRetention(r Retention) FileOption {
+	return func(f *FileHandler) { f.retention = r }
+}
+
+// prune removes archives outside of the retention policy
+func (f *FileHandler) prune() error {
+	f.muFile.Lock()
+	r := f.retention
+	if r.isZero() {
+		f.muFile.Unlock()
+		return nil
+	}
+	all, err := f.archives()
+	f.muFile.Unlock()
+	if err != nil {
+		return err
+	}
+
+	var (
+		errs  []error
+		total int64 // partial archives take up space too, but are never removed here
+		now   = time.Now()
+	)
+	archives := slices.DeleteFunc(all, func(a archive) bool {
+		if a.partial {
+			total += a.size
+		}
+		return a.pending || a.partial
+	})
+	for i, a := range archives {
+		total += a.size
+
+		expired := r.MaxAge > 0 && now.Sub(a.created) > r.MaxAge
+		tooMany := r.MaxArchives > 0 && i >= r.MaxArchives
+		tooBig := r.MaxBytes > 0 && total > r.MaxBytes
+		if !expired && !tooMany && !tooBig {
+			continue
+		}
+
+		if err := os.Remove(a.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,56 @@
+package log_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lattesec/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetentionPrunesOnStart(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now().UTC()
+
+	archive := func(age time.Duration) string {
+		return "app.log-" + now.Add(-age).Format("2006-01-02_15-04-05") + ".gz"
+	}
+	files := map[string]int{
+		archive(1 * time.Hour):  10,
+		archive(2 * time.Hour):  10,
+		archive(3 * time.Hour):  10,
+		archive(72 * time.Hour): 10,
+		"app.log-notes.gz":      10, // not an archive
+		"other.log-" + now.Format("2006-01-02_15-04-05") + ".gz": 10,
+	}
+	for name, size := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), make([]byte, size), 0o600))
+	}
+
+	h, err := log.NewFileHandler(filepath.Join(dir, "app.log"), log.WithRetention(log.Retention{
+		MaxArchives: 3,
+		MaxAge:      48 * time.Hour,
+		MaxBytes:    25,
+	}))
+	require.NoError(t, err)
+	require.NoError(t, h.Close())
+
+	for name, kept := range map[string]bool{
+		archive(1 * time.Hour):  true,
+		archive(2 * time.Hour):  true,
+		archive(3 * time.Hour):  false, // over MaxBytes
+		archive(72 * time.Hour): false, // over MaxAge and MaxArchives
+		"app.log-notes.gz":      true,
+		"other.log-" + now.Format("2006-01-02_15-04-05") + ".gz": true,
+	} {
+		_, err := os.Stat(filepath.Join(dir, name))
+		if kept {
+			assert.NoError(t, err, "expected %s to be kept", name)
+		} else {
+			assert.ErrorIs(t, err, os.ErrNotExist, "expected %s to be pruned", name)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package log_test
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lattesec/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readGzip(t *testing.T, path string) string {
+	t.Helper()
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+
+	gz, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	data, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestRotationKeepsEveryLine(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "busy.log")
+
+	every := log.ScheduleFunc(func(now time.Time) time.Time { return now.Add(5 * time.Millisecond) })
+	l, err := log.NewLogger().
+		WithLevel(log.INFO).
+		WithStderr(false).
+		WithStdout(false).
+		WithFile(path, -1, log.WithSchedule(every)).
+		WithBackpressure(log.Backpressure{Mode: log.Block}).
+		Build()
+	require.NoError(t, err)
+	require.NoError(t, l.Start(), "failed to start logger")
+
+	const lines = 5000
+	for i := range lines {
+		l.Info().Msgf("line %d", i).Send()
+		if i%500 == 0 {
+			time.Sleep(10 * time.Millisecond) // let a few rotations happen
+		}
+	}
+	require.NoError(t, l.Close())
+
+	pending, err := filepath.Glob(filepath.Join(dir, "busy.log-*"))
+	require.NoError(t, err)
+
+	var all strings.Builder
+	archives := 0
+	for _, p := range pending {
+		require.True(t, strings.HasSuffix(p, ".gz"), "expected %s to be compressed", p)
+		all.WriteString(readGzip(t, p))
+		archives++
+	}
+	current, err := os.ReadFile(path)
+	require.NoError(t, err)
+	all.Write(current)
+	assert.Greater(t, archives, 1, "expected several rotations")
+
+	seen := make(map[string]int, lines)
+	sc := bufio.NewScanner(strings.NewReader(all.String()))
+	for sc.Scan() {
+		if _, msg, ok := strings.Cut(sc.Text(), "line "); ok {
+			seen[msg]++
+		}
+	}
+	for i := range lines {
+		assert.Equal(t, 1, seen[fmt.Sprint(i)], "line %d", i)
+	}
+}
+
+func TestPendingArchiveCompressedOnStart(t *testing.T) {
+	dir := t.TempDir()
+	pending := filepath.Join(dir, "app.log-"+time.Now().UTC().Format("2006-01-02_15-04-05"))
+	require.NoError(t, os.WriteFile(pending, []byte("left behind\n"), 0o600))
+
+	h, err := log.NewFileHandler(filepath.Join(dir, "app.log"))
+	require.NoError(t, err)
+	require.NoError(t, h.Close())
+
+	_, err = os.Stat(pending)
+	assert.ErrorIs(t, err, os.ErrNotExist, "expected the pending archive to be removed")
+	assert.Equal(t, "left behind\n", readGzip(t, pending+".gz"))
+}
+
+func TestPartialArchivesRemovedOnStart(t *testing.T) {
+	dir := t.TempDir()
+	stamp := time.Now().UTC().Format("2006-01-02_15-04-05")
+	orphan := filepath.Join(dir, "app.log-"+stamp+".gz.tmp")
+	require.NoError(t, os.WriteFile(orphan, []byte("half a gzip"), 0o600))
+
+	pending := filepath.Join(dir, "app.log-"+stamp+".1")
+	require.NoError(t, os.WriteFile(pending, []byte("left behind\n"), 0o600))
+	require.NoError(t, os.WriteFile(pending+".gz.tmp", []byte("half a gzip"), 0o600))
+
+	h, err := log.NewFileHandler(filepath.Join(dir, "app.log"))
+	require.NoError(t, err)
+	require.NoError(t, h.Close())
+
+	_, err = os.Stat(orphan)
+	assert.ErrorIs(t, err, os.ErrNotExist, "expected the partial archive to be removed")
+	_, err = os.Stat(pending + ".gz.tmp")
+	assert.ErrorIs(t, err, os.ErrNotExist, "expected the partial archive to be replaced")
+	assert.Equal(t, "left behind\n", readGzip(t, pending+".gz"))
+}
+
+func TestRotationOnWriteRespectsMaxSize(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "capped.log")
+
+	const maxSize = 256
+	l, err := log.NewLogger().
+		WithLevel(log.INFO).
+		WithStderr(false).
+		WithStdout(false).
+		WithFile(path, maxSize).
+		Build()
+	require.NoError(t, err)
+	require.NoError(t, l.Start(), "failed to start logger")
+
+	for i := range 100 {
+		l.Info().Msgf("line %d", i).Send()
+	}
+	require.NoError(t, l.Close())
+
+	archives, err := filepath.Glob(filepath.Join(dir, "capped.log-*.gz"))
+	require.NoError(t, err)
+	assert.Greater(t, len(archives), 5, "expected rotations without waiting for the ticker")
+	for _, a := range archives {
+		assert.LessOrEqual(t, len(readGzip(t, a)), maxSize, a)
+	}
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, info.Size(), int64(maxSize))
+}
+
+func TestFileHandlerReopen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "moved.log")
+
+	l, err := log.NewLogger().
+		WithLevel(log.INFO).
+		WithStderr(false).
+		WithStdout(false).
+		WithFile(path, -1).
+		Build()
+	require.NoError(t, err)
+	require.NoError(t, l.Start(), "failed to start logger")
+	defer func() { require.NoError(t, l.Close()) }()
+
+	l.Info().Msg("before move").Send()
+	require.NoError(t, l.Flush(context.Background()))
+
+	// logrotate's move-and-create
+	require.NoError(t, os.Rename(path, path+".1"))
+	require.NoError(t, log.ReopenFiles())
+
+	l.Info().Msg("after move").Send()
+	require.NoError(t, l.Flush(context.Background()))
+
+	moved, err := os.ReadFile(path + ".1")
+	require.NoError(t, err)
+	assert.Contains(t, string(moved), "before move")
+	assert.NotContains(t, string(moved), "after move")
+
+	current, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(current), "after move")
+	assert.NotContains(t, string(current), "before move")
+}
+
+func TestArchiveNamingCompressionAndModes(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	const maxSize = 128
+	l, err := log.NewLogger().
+		WithLevel(log.INFO).
+		WithStderr(false).
+		WithStdout(false).
+		WithFile(path, maxSize,
+			log.WithArchiveNaming(log.ArchiveNaming{Dir: "archive", Sequence: true}),
+			log.WithCompression(log.Compression{Disabled: true}),
+			log.WithRetention(log.Retention{MaxArchives: 2}),
+			log.WithFileMode(0o640),
+			log.WithDirMode(0o750),
+		).
+		Build()
+	require.NoError(t, err)
+	require.NoError(t, l.Start(), "failed to start logger")
+
+	for i := range 20 {
+		l.Info().Msgf("line %d", i).Send()
+	}
+	require.NoError(t, l.Close())
+
+	entries, err := os.ReadDir(filepath.Join(dir, "archive"))
+	require.NoError(t, err)
+	require.Len(t, entries, 2, "expected retention to keep the newest archives")
+
+	var seqs []int
+	for _, e := range entries {
+		var seq int
+		_, err := fmt.Sscanf(e.Name(), "app.log.%d", &seq)
+		require.NoError(t, err, e.Name())
+		seqs = append(seqs, seq)
+	}
+	slices.Sort(seqs)
+	assert.Greater(t, seqs[0], 1, "expected the oldest archives to be pruned")
+	assert.Equal(t, seqs[0]+1, seqs[1])
+
+	newest := fmt.Sprintf("app.log.%d", seqs[1])
+	data, err := os.ReadFile(filepath.Join(dir, "archive", newest))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "line", "expected an uncompressed archive")
+
+	for _, p := range []string{path, filepath.Join(dir, "archive", newest)} {
+		info, err := os.Stat(p)
+		require.NoError(t, err)
+		assert.NotZero(t, info.Mode().Perm()&0o040, "expected %s to be group readable", p)
+		assert.Zero(t, info.Mode().Perm()&0o007, "expected %s to be private to the group", p)
+	}
+	info, err := os.Stat(filepath.Join(dir, "archive"))
+	require.NoError(t, err)
+	assert.NotZero(t, info.Mode().Perm()&0o050, "expected the archive dir to be group accessible")
+}
+
+func TestFileHandlerRotate(t *testing.T) {
+	dir := t.TempDir()
+
+	hooked := make(chan string, 1)
+	h, err := log.NewFileHandler(filepath.Join(dir, "round.log"),
+		log.WithRotateHook(func(archive string) { hooked <- archive }))
+	require.NoError(t, err)
+	defer func() { require.NoError(t, h.Close()) }()
+
+	archive, err := h.Rotate(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, archive, "expected an empty log not to be rotated")
+
+	h.Handle("round", log.NewLogMessage().Info().Msg("round 1 over"))
+	require.NoError(t, h.Flush(context.Background()))
+
+	archive, err = h.Rotate(context.Background())
+	require.NoError(t, err)
+	assert.Contains(t, readGzip(t, archive), "round 1 over")
+
+	select {
+	case got := <-hooked:
+		assert.Equal(t, archive, got)
+	case <-time.After(time.Second):
+		t.Fatal("expected the rotate hook to run")
+	}
+}
+
+func TestRotateHookLogsWhileClosing(t *testing.T) {
+	dir := t.TempDir()
+
+	var l *log.Logger
+	hooked := make(chan struct{})
+	h, err := log.NewFileHandler(filepath.Join(dir, "upload.log"),
+		log.WithRotateHook(func(archive string) {
+			defer close(hooked)
+			time.Sleep(200 * time.Millisecond) // still running once Close starts
+			l.Info().Msgf("archived %s", archive).Send()
+		}))
+	require.NoError(t, err)
+
+	l, err = log.NewLogger().
+		WithLevel(log.INFO).
+		WithStderr(false).
+		WithStdout(false).
+		WithHandlers(h).
+		Build()
+	require.NoError(t, err)
+	require.NoError(t, l.Start(), "failed to start logger")
+
+	l.Info().Msg("round 1 over").Send()
+	require.NoError(t, l.Flush(context.Background()))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	_, _ = h.Rotate(ctx) // the hook may still be running
+	cancel()
+
+	closed := make(chan error, 1)
+	go func() { closed <- l.Close() }()
+	select {
+	case err := <-closed:
+		require.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected Close not to wait for the rotate hook")
+	}
+	<-hooked
+}
@@ -0,0 +1,315 @@
+package log
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// RoutingOptions bounds the files a RoutingFileHandler keeps open
+type RoutingOptions struct {
+	MaxOpen     int           // files kept open at most, the least recently used is closed first. defaults to 64
+	IdleTimeout time.Duration // files unused for this long are closed, defaults to 5m, negative disables
+	Missing     string        // fills in keys missing from Meta, defaults to "unknown"
+}
+
+const (
+	defaultRoutingMaxOpen     = 64
+	defaultRoutingIdleTimeout = 5 * time.Minute
+)
+
+// RoutingFileHandler writes every message to the file its Meta picks,
+// by filling in a path template
+//
+//	h, err := log.NewRoutingFileHandler("logs/{challenge}/{team}.log", log.RoutingOptions{})
+//
+// Keys inside groups are dotted like {round.id}. Values are sanitized,
+// so they can't leave their place in the path.
+// Files are opened like with NewFileHandler on first use and rotate like any other.
+// The handler's level applies before routing, its formatter, backpressure and queue size
+// as of Start are passed on to the files it opens.
+// A file already open elsewhere is shared, it fails with ErrFormatterConflict
+// unless its formatter is equal to the handler's
+type RoutingFileHandler struct {
+	BaseHandler
+
+	template []templatePart
+	opts     RoutingOptions
+	fileOpts []FileOption
+
+	// passed on to opened files, set by StartFunc
+	fileBackpressure Backpressure
+	fileQueueSize    int
+
+	muRoutes sync.Mutex
+	routes   map[string]*list.Element // of *route, by path
+	lru      *list.List               // most recently used first
+}
+
+type templatePart struct {
+	text  string // literal text, or the Meta key
+	isKey bool
+}
+
+type route struct {
+	path     string
+	handler  *FileHandler
+	lastUsed time.Time
+}
+
+// NewRoutingFileHandler creates a RoutingFileHandler, fileOpts apply to every file it opens
+func NewRoutingFileHandler(template string, opts RoutingOptions, fileOpts ...FileOption) (*RoutingFileHandler, error) {
+	parts, err := parsePathTemplate(template)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.MaxOpen <= 0 {
+		opts.MaxOpen = defaultRoutingMaxOpen
+	}
+	if opts.IdleTimeout == 0 {
+		opts.IdleTimeout = defaultRoutingIdleTimeout
+	}
+	if opts.Missing == "" {
+		opts.Missing = "unknown"
+	}
+
+	r := &RoutingFileHandler{
+		template: parts,
+		opts:     opts,
+		fileOpts: fileOpts,
+		routes:   map[string]*list.Element{},
+		lru:      list.New(),
+	}
+	r.BaseHandler = BaseHandler{
+		Name: "routing file handler " + template,
+		StartFunc: func(ctx context.Context, lh LogHandler) error {
+			r.fileBackpressure, r.fileQueueSize = r.backpressure, r.queueSize // mu is held by Start
+			return nil
+		},
+		HandleFunc: func(ctx context.Context, msg *LogMessage) error {
+			return r.dispatch(r.resolve(msg), msg)
+		},
+		FlushFunc: func(ctx context.Context, lh LogHandler) error {
+			return r.flushRoutes(ctx)
+		},
+		CloseFunc: func(ctx context.Context, lh LogHandler) error {
+			return r.closeRoutes(func(*route) bool { return true })
+		},
+		Subprocesses: []func(context.Context) error{r.closeIdle},
+	}
+	return r, nil
+}
+
+func parsePathTemplate(template string) ([]templatePart, error) {
+	var parts []templatePart
+	for rest := template; rest != ""; {
+		opening, closing := strings.IndexByte(rest, '{'), strings.IndexByte(rest, '}')
+		if opening < 0 {
+			if closing >= 0 {
+				return nil, fmt.Errorf("%w: %q: unexpected }", ErrInvalidPathTemplate, template)
+			}
+			parts = append(parts, templatePart{text: rest})
+			break
+		}
+		if closing < opening {
+			return nil, fmt.Errorf("%w: %q: unclosed {", ErrInvalidPathTemplate, template)
+		}
+
+		key := rest[opening+1 : closing]
+		if key == "" || strings.Contains(key, "{") {
+			return nil, fmt.Errorf("%w: %q: invalid key %q", ErrInvalidPathTemplate, template, key)
+		}
+		if opening > 0 {
+			parts = append(parts, templatePart{text: rest[:opening]})
+		}
+		parts = append(parts, templatePart{text: key, isKey: true})
+		rest = rest[closing+1:]
+	}
+
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("%w: empty template", ErrInvalidPathTemplate)
+	}
+	return parts, nil
+}
+
+// resolve fills in the path template from msg's Meta
+func (r *RoutingFileHandler) resolve(msg *LogMessage) string {
+	var sb strings.Builder
+	for _, p := range r.template {
+		if !p.isKey {
+			sb.WriteString(p.text)
+			continue
+		}
+
+		val := r.opts.Missing
+		flattenFields("", msg.Meta, func(key string, v Value) {
+			if key == p.text { // the last one wins, like fields of a message over bound fields
+				val = v.String()
+			}
+		})
+		sb.WriteString(sanitizePathValue(val))
+	}
+	return filepath.Clean(sb.String())
+}
+
+// sanitizePathValue keeps a Meta value from changing the shape of the path
+func sanitizePathValue(v string) string {
+	v = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' || r == '.' {
+			return r
+		}
+		return '_'
+	}, v)
+	if strings.Trim(v, ".") == "" {
+		return strings.Repeat("_", max(len(v), 1)) // empty, . and ..
+	}
+	return v
+}
+
+// dispatch hands msg to the file handler of path, opening it if needed
+func (r *RoutingFileHandler) dispatch(path string, msg *LogMessage) (err error) {
+	var evicted []*FileHandler
+	defer func() { err = errors.Join(err, closeFileHandlers(evicted)) }() // after unlocking muRoutes
+
+	r.muRoutes.Lock()
+	defer r.muRoutes.Unlock()
+
+	if el, ok := r.routes[path]; ok {
+		rt := el.Value.(*route)
+		rt.lastUsed = time.Now()
+		r.lru.MoveToFront(el)
+		rt.handler.Handle(msg.LoggerName(), msg)
+		return nil
+	}
+
+	for r.lru.Len() >= r.opts.MaxOpen {
+		evicted = append(evicted, r.evict(r.lru.Back()))
+	}
+
+	h, err := r.openFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to open routed log %s: %w", path, err)
+	}
+
+	r.routes[path] = r.lru.PushFront(&route{path: path, handler: h, lastUsed: time.Now()})
+	h.Handle(msg.LoggerName(), msg)
+	return nil
+}
+
+// openFile opens the file handler of path with the routing handler's settings
+func (r *RoutingFileHandler) openFile(path string) (*FileHandler, error) {
+	formatter := r.GetFormatter()
+
+	fh := newFileHandler(path, r.fileOpts...)
+	fh.SetFormatter(formatter)
+	fh.SetBackpressure(r.fileBackpressure)
+	if err := fh.SetQueueSize(r.fileQueueSize); err != nil {
+		return nil, err
+	}
+
+	h, err := newRefCounted(fh)
+	if err != nil {
+		return nil, err
+	}
+	if h != fh && !sameFormatter(h.GetFormatter(), formatter) {
+		return nil, errors.Join(ErrFormatterConflict, h.Close())
+	}
+	return h, nil
+}
+
+// sameFormatter reports whether a and b are equal,
+// formatters that can't be compared like a FormatterFunc never are
+func sameFormatter(a, b Formatter) bool {
+	return reflect.TypeOf(a) == reflect.TypeOf(b) && reflect.TypeOf(a).Comparable() && a == b
+}
+
+// evict removes a route, callers close its handler once muRoutes is unlocked,
+// its queued messages are still written then
+//
+// callers responsibility to hold muRoutes
+func (r *RoutingFileHandler) evict(el *list.Element) *FileHandler {
+	rt := r.lru.Remove(el).(*route)
+	delete(r.routes, rt.path)
+	return rt.handler
+}
+
+func closeFileHandlers(handlers []*FileHandler) error {
+	var errs []error
+	for _, h := range handlers {
+		errs = append(errs, h.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func (r *RoutingFileHandler) closeRoutes(match func(*route) bool) error {
+	r.muRoutes.Lock()
+	var evicted []*FileHandler
+	for el := r.lru.Back(); el != nil; {
+		prev := el.Prev()
+		if match(el.Value.(*route)) {
+			evicted = append(evicted, r.evict(el))
+		}
+		el = prev
+	}
+	r.muRoutes.Unlock()
+
+	return closeFileHandlers(evicted)
+}
+
+func (r *RoutingFileHandler) flushRoutes(ctx context.Context) error {
+	r.muRoutes.Lock()
+	handlers := make([]*FileHandler, 0, r.lru.Len())
+	for el := r.lru.Front(); el != nil; el = el.Next() {
+		handlers = append(handlers, el.Value.(*route).handler)
+	}
+	r.muRoutes.Unlock()
+
+	var errs []error
+	for _, h := range handlers {
+		if err := h.Flush(ctx); err != nil && !errors.Is(err, ErrNotStarted) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r *RoutingFileHandler) closeIdle(ctx context.Context) error {
+	if r.opts.IdleTimeout < 0 {
+		return nil
+	}
+
+	ticker := time.NewTicker(max(r.opts.IdleTimeout/2, time.Millisecond))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			err := r.closeRoutes(func(rt *route) bool { return time.Since(rt.lastUsed) >= r.opts.IdleTimeout })
+			if err != nil {
+				Error().Msgf("failed to close idle logs: %v", err).Send()
+			}
+		}
+	}
+}
+
+// OpenFiles returns the paths of the files open right now, most recently used first
+func (r *RoutingFileHandler) OpenFiles() []string {
+	r.muRoutes.Lock()
+	defer r.muRoutes.Unlock()
+
+	paths := make([]string, 0, r.lru.Len())
+	for el := r.lru.Front(); el != nil; el = el.Next() {
+		paths = append(paths, el.Value.(*route).path)
+	}
+	return paths
+}
@@ -0,0 +1,99 @@
+package log_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lattesec/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPathTemplateErrors(t *testing.T) {
+	for _, tmpl := range []string{"", "logs/{team", "logs/team}.log", "logs/{}.log", "logs/{a{b}.log"} {
+		_, err := log.NewRoutingFileHandler(tmpl, log.RoutingOptions{})
+		assert.ErrorIs(t, err, log.ErrInvalidPathTemplate, tmpl)
+	}
+}
+
+func TestRoutingFileHandler(t *testing.T) {
+	dir := t.TempDir()
+
+	h, err := log.NewRoutingFileHandler(filepath.Join(dir, "{challenge}", "{team}.log"), log.RoutingOptions{})
+	require.NoError(t, err)
+	l, err := log.NewLogger().
+		WithLevel(log.INFO).
+		WithStderr(false).
+		WithStdout(false).
+		WithHandlers(h).
+		Build()
+	require.NoError(t, err)
+	require.NoError(t, l.Start(), "failed to start logger")
+
+	web := l.With(log.String("challenge", "web"))
+	web.Info().Msg("solved").WithFields(log.String("team", "red")).Send()
+	web.Info().Msg("escape").WithFields(log.String("team", "../../etc/passwd")).Send()
+	web.Info().Msg("anonymous").Send()
+	l.Info().Msg("nested").WithFields(log.Group("challenge", log.Int("id", 1)), log.String("team", "blue")).Send()
+	require.NoError(t, l.Close())
+
+	for path, msg := range map[string]string{
+		"web/red.log":              "solved",
+		"web/.._.._etc_passwd.log": "escape",
+		"web/unknown.log":          "anonymous",
+		"unknown/blue.log":         "nested", // challenge is a group
+	} {
+		data, err := os.ReadFile(filepath.Join(dir, path))
+		require.NoError(t, err, path)
+		assert.Contains(t, string(data), msg, path)
+	}
+}
+
+func TestRoutingFileHandlerEviction(t *testing.T) {
+	dir := t.TempDir()
+
+	h, err := log.NewRoutingFileHandler(filepath.Join(dir, "{team}.log"), log.RoutingOptions{
+		MaxOpen:     2,
+		IdleTimeout: 50 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	require.NoError(t, h.Start())
+	defer func() { require.NoError(t, h.Close()) }()
+
+	for _, team := range []string{"a", "b", "c", "b"} {
+		h.Handle("", log.NewLogMessage().Info().Msg("hi").WithFields(log.String("team", team)))
+	}
+	require.NoError(t, h.Flush(context.Background()))
+	assert.Equal(t, []string{filepath.Join(dir, "b.log"), filepath.Join(dir, "c.log")}, h.OpenFiles(),
+		"expected the least recently used file to be closed")
+
+	data, err := os.ReadFile(filepath.Join(dir, "a.log"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "hi", "expected an evicted file to be written")
+
+	require.Eventually(t, func() bool { return len(h.OpenFiles()) == 0 }, time.Second, 10*time.Millisecond,
+		"expected idle files to be closed")
+}
+
+func TestRoutingFileHandlerFormatterConflict(t *testing.T) {
+	dir := t.TempDir()
+
+	shared, err := log.NewFileHandler(filepath.Join(dir, "a.log"))
+	require.NoError(t, err)
+	defer func() { require.NoError(t, shared.Close()) }()
+
+	h, err := log.NewRoutingFileHandler(filepath.Join(dir, "{team}.log"), log.RoutingOptions{})
+	require.NoError(t, err)
+	h.SetFormatter(log.JSONFormatter{})
+	require.NoError(t, h.Start())
+	defer func() { require.NoError(t, h.Close()) }()
+
+	h.Handle("", log.NewLogMessage().Info().Msg("hi").WithFields(log.String("team", "a")))
+	require.NoError(t, h.Flush(context.Background()))
+
+	assert.Empty(t, h.OpenFiles(), "expected the shared file not to be routed to")
+	assert.Equal(t, log.DefaultFormatter, shared.GetFormatter(), "expected the shared formatter to be kept")
+}
@@ -0,0 +1,183 @@
+package log
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule decides when time-based rotations happen
+type Schedule interface {
+	// Next returns the first boundary strictly after t, in t's location,
+	// or the zero time if there is none
+	Next(t time.Time) time.Time
+}
+
+// ScheduleFunc allows using ordinary functions as Schedules
+type ScheduleFunc func(t time.Time) time.Time
+
+func (fn ScheduleFunc) Next(t time.Time) time.Time { return fn(t) }
+
+var (
+	// Hourly rotates at the start of every hour
+	Hourly Schedule = ScheduleFunc(func(t time.Time) time.Time {
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+	})
+
+	// Daily rotates at midnight
+	Daily Schedule = ScheduleFunc(func(t time.Time) time.Time {
+		return time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+	})
+)
+
+// cronSchedule is a parsed cron expression, each field is a bitset
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+
+	domAny, dowAny bool // unrestricted day fields, see dayMatches
+}
+
+var cronDescriptors = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+}
+
+// ParseCron parses a standard 5-field cron expression
+//
+//	minute hour day-of-month month day-of-week
+//
+// Fields accept *, numbers, ranges (1-5), steps (*/15, 0-30/5) and lists (1,15).
+// Day-of-week runs from 0 (Sunday) to 6, 7 is accepted for Sunday.
+// @hourly, @daily, @midnight, @weekly, @monthly and @yearly are accepted too
+func ParseCron(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if d, ok := cronDescriptors[spec]; ok {
+		spec = d
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%w: %q: expected 5 fields", ErrInvalidSchedule, spec)
+	}
+
+	var (
+		c   cronSchedule
+		err error
+	)
+	bounds := []struct {
+		set             *uint64
+		lowest, highest int
+	}{
+		{&c.minute, 0, 59},
+		{&c.hour, 0, 23},
+		{&c.dom, 1, 31},
+		{&c.month, 1, 12},
+		{&c.dow, 0, 7},
+	}
+	for i, b := range bounds {
+		if *b.set, err = parseCronField(fields[i], b.lowest, b.highest); err != nil {
+			return nil, fmt.Errorf("%w: %q: %w", ErrInvalidSchedule, spec, err)
+		}
+	}
+
+	if c.dow&(1<<7) != 0 { // 7 is Sunday
+		c.dow |= 1
+	}
+	c.domAny = strings.HasPrefix(fields[2], "*")
+	c.dowAny = strings.HasPrefix(fields[4], "*")
+	return c, nil
+}
+
+// MustParseCron is ParseCron but panics on invalid expressions
+func MustParseCron(spec string) Schedule {
+	s, err := ParseCron(spec)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func parseCronField(field string, lowest, highest int) (uint64, error) {
+	var set uint64
+	for part := range strings.SplitSeq(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepStr); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q", part)
+			}
+		}
+
+		lo, hi := lowest, highest
+		if rng != "*" {
+			loStr, hiStr, isRange := strings.Cut(rng, "-")
+
+			var err error
+			if lo, err = strconv.Atoi(loStr); err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(hiStr); err != nil {
+					return 0, fmt.Errorf("invalid range %q", part)
+				}
+			} else if hasStep {
+				hi = highest // 5/15 means from 5 onwards
+			}
+		}
+
+		if lo < lowest || hi > highest || lo > hi {
+			return 0, fmt.Errorf("%q out of range %d-%d", part, lowest, highest)
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+func (c cronSchedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+
+	// nothing may match (like February 30th), give up after 5 years
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// when both day fields are restricted either may match, like cron does
+func (c cronSchedule) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if !c.domAny && !c.dowAny {
+		return dom || dow
+	}
+	return dom && dow
+}
@@ -0,0 +1,85 @@
+package log_test
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lattesec/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchedules(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skip("no tzdata:", err)
+	}
+
+	at := time.Date(2025, 3, 14, 15, 9, 26, 0, tokyo)
+	assert.Equal(t, time.Date(2025, 3, 14, 16, 0, 0, 0, tokyo), log.Hourly.Next(at))
+	assert.Equal(t, time.Date(2025, 3, 15, 0, 0, 0, 0, tokyo), log.Daily.Next(at))
+
+	for spec, want := range map[string]time.Time{
+		"*/15 * * * *":   time.Date(2025, 3, 14, 15, 15, 0, 0, tokyo),
+		"0 9-17/4 * * *": time.Date(2025, 3, 14, 17, 0, 0, 0, tokyo),
+		"30 2 1 * *":     time.Date(2025, 4, 1, 2, 30, 0, 0, tokyo),
+		"0 0 * * 7":      time.Date(2025, 3, 16, 0, 0, 0, 0, tokyo), // Sunday
+		"0 0 13 * 5":     time.Date(2025, 3, 21, 0, 0, 0, 0, tokyo), // the 13th or a Friday
+		"@monthly":       time.Date(2025, 4, 1, 0, 0, 0, 0, tokyo),
+	} {
+		s, err := log.ParseCron(spec)
+		require.NoError(t, err, spec)
+		assert.Equal(t, want, s.Next(at), spec)
+	}
+
+	assert.True(t, log.MustParseCron("0 0 30 2 *").Next(at).IsZero(), "expected February 30th to never happen")
+
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		_, err := log.ParseCron(spec)
+		assert.ErrorIs(t, err, log.ErrInvalidSchedule, spec)
+	}
+}
+
+func TestFileHandlerScheduledRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "scheduled.log")
+
+	var fired atomic.Bool
+	once := log.ScheduleFunc(func(now time.Time) time.Time {
+		if fired.Swap(true) {
+			return time.Time{}
+		}
+		return now.Add(100 * time.Millisecond)
+	})
+	l, err := log.NewLogger().
+		WithLevel(log.INFO).
+		WithStderr(false).
+		WithStdout(false).
+		WithFile(path, -1, log.WithSchedule(once)).
+		Build()
+	require.NoError(t, err)
+	require.NoError(t, l.Start(), "failed to start logger")
+	defer func() { require.NoError(t, l.Close()) }()
+
+	l.Info().Msg("before rotation").Send()
+
+	var archives []string
+	require.Eventually(t, func() bool {
+		archives, _ = filepath.Glob(filepath.Join(dir, "scheduled.log-*.gz"))
+		return len(archives) > 0
+	}, 5*time.Second, 10*time.Millisecond, "expected a scheduled rotation")
+
+	f, err := os.Open(archives[0])
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+	gz, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	data, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "before rotation")
+}
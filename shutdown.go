@@ -0,0 +1,81 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+// how long Fatal waits for handlers to shut down before exiting
+const fatalShutdownTimeout = 5 * time.Second
+
+var (
+	muLoggers sync.Mutex
+	loggers   []*loggerCore // running loggers, in start order
+)
+
+func registerLogger(core *loggerCore) {
+	muLoggers.Lock()
+	loggers = append(loggers, core)
+	muLoggers.Unlock()
+}
+
+func unregisterLogger(core *loggerCore) {
+	muLoggers.Lock()
+	loggers = slices.DeleteFunc(loggers, func(c *loggerCore) bool { return c == core })
+	muLoggers.Unlock()
+}
+
+// Close shuts down every logger and handler, see Shutdown
+func Close() error { return Shutdown(context.Background()) }
+
+// Shutdown runs the cleanup registry: application cleanups, then running loggers,
+// then handlers no logger closed, then the default stdout and stderr handlers
+//
+// Closing a handler drains its queue. Each cleanup gets its own timeout and
+// Shutdown stops waiting once ctx is done, reporting every cleanup that
+// failed or did not finish in time by name
+func Shutdown(ctx context.Context) error {
+	var errs []error
+	for {
+		c, ok := takeNextCleanup()
+		if !ok {
+			return errors.Join(errs...)
+		}
+		errs = append(errs, c.run(ctx))
+	}
+}
+
+// flushAll flushes the handlers of every running logger
+// and the default stdout and stderr handlers
+func flushAll(ctx context.Context) error {
+	muLoggers.Lock()
+	running := slices.Clone(loggers)
+	muLoggers.Unlock()
+
+	var handlers []LogHandler
+	for _, h := range []*WriterHandler{DefaultStdoutHandler.Load(), DefaultStderrHandler.Load()} {
+		if h != nil {
+			handlers = append(handlers, h)
+		}
+	}
+	for _, core := range running {
+		core.mu.RLock()
+		for _, h := range core.handlers {
+			if !slices.Contains(handlers, h) {
+				handlers = append(handlers, h)
+			}
+		}
+		core.mu.RUnlock()
+	}
+
+	var errs []error
+	for _, h := range handlers {
+		if err := h.Flush(ctx); err != nil && !errors.Is(err, ErrNotStarted) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
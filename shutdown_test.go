@@ -0,0 +1,119 @@
+package log_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/lattesec/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Shutdown closes the default stdout and stderr handlers, put them back for other tests
+func restoreStdHandlers(t *testing.T) {
+	t.Cleanup(func() {
+		require.NoError(t, log.RegisterStdoutHandler(log.NewWriterHandler(os.Stdout)))
+		require.NoError(t, log.RegisterStderrHandler(log.NewWriterHandler(os.Stderr)))
+	})
+}
+
+func TestShutdownDrainsAndReportsStuckHandlers(t *testing.T) {
+	restoreStdHandlers(t)
+
+	var buf bytes.Buffer
+	l, err := log.NewLogger().
+		WithLevel(log.INFO).
+		WithStderr(false).
+		WithStdout(false).
+		WithHandlers(log.NewWriterHandler(&buf)).
+		Name("drained").
+		Build()
+	require.NoError(t, err)
+	require.NoError(t, l.Start(), "failed to start logger")
+
+	gate := make(chan struct{})
+	defer close(gate)
+	stuck, err := log.NewLogger().
+		WithLevel(log.INFO).
+		WithStderr(false).
+		WithStdout(false).
+		WithHandlers(&log.BaseHandler{HandleFunc: func(context.Context, *log.LogMessage) error {
+			<-gate
+			return nil
+		}}).
+		Name("stuck").
+		Build()
+	require.NoError(t, err)
+	require.NoError(t, stuck.Start(), "failed to start logger")
+
+	orphan := log.NewWriterHandler(&bytes.Buffer{})
+	require.NoError(t, orphan.Start())
+
+	for i := range 100 {
+		l.Info().Msgf("queued %d", i).Send()
+	}
+	stuck.Info().Msg("never written").Send()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err = log.Shutdown(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Contains(t, err.Error(), `logger "stuck" did not finish in time`)
+	assert.NotContains(t, err.Error(), `"drained"`)
+
+	assert.False(t, l.IsRunning())
+	assert.Contains(t, buf.String(), "queued 99", "expected queues to be drained")
+
+	// past the deadline the remaining steps finish in the background
+	require.Eventually(t, func() bool { return !orphan.IsRunning() }, time.Second, time.Millisecond,
+		"expected handlers without a logger to be closed")
+	require.Eventually(t, func() bool { return !log.DefaultStdoutHandler.Load().IsRunning() }, time.Second, time.Millisecond,
+		"expected default handlers to be closed")
+}
+
+func TestCleanupRegistryOrder(t *testing.T) {
+	restoreStdHandlers(t)
+
+	var order []string
+	record := func(name string, err error) func(context.Context) error {
+		return func(context.Context) error {
+			order = append(order, name)
+			return err
+		}
+	}
+
+	l, err := log.NewLogger().
+		WithLevel(log.INFO).
+		WithStderr(false).
+		WithStdout(false).
+		WithHandlers(&log.BaseHandler{
+			Name:       "recording handler",
+			HandleFunc: func(context.Context, *log.LogMessage) error { return nil },
+			CloseFunc: func(context.Context, log.LogHandler) error {
+				order = append(order, "handler")
+				return nil
+			},
+		}).
+		WithCleanup(func() { order = append(order, "builder cleanup") }).
+		Name("ordered").
+		Build()
+	require.NoError(t, err)
+	require.NoError(t, l.Start(), "failed to start logger")
+
+	log.RegisterCleanup(log.Cleanup{Name: "late", Priority: log.CleanupPriorityStd + 1, Fn: record("late", nil)})
+	log.RegisterCleanup(log.Cleanup{Name: "first", Fn: record("first", nil)})
+	log.RegisterCleanup(log.Cleanup{Name: "failing", Priority: log.CleanupPriorityLogger - 1, Fn: record("failing", errors.New("boom"))})
+	unregister := log.RegisterCleanup(log.Cleanup{Name: "removed", Fn: record("removed", nil)})
+	unregister()
+
+	err = log.Shutdown(context.Background())
+	require.Error(t, err)
+	assert.Equal(t, "failing: boom", err.Error(), "expected failures to be reported by name")
+	assert.Equal(t, []string{"builder cleanup", "first", "failing", "handler", "late"}, order)
+	assert.False(t, l.IsRunning())
+}
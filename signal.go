@@ -0,0 +1,142 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// SignalAction is what HandleSignals does after logs are handled
+type SignalAction int
+
+const (
+	// shut down, then re-raise the signal with its default behaviour
+	SignalReraise SignalAction = iota
+	// shut down, then exit with SignalOptions.ExitCode
+	SignalExit
+	// only flush and keep running, for applications handling the signal themselves
+	SignalFlush
+	// reopen the logs of all file handlers and keep running, see ReopenFiles
+	SignalReopen
+)
+
+type SignalOptions struct {
+	Signals  []os.Signal   // defaults to SIGINT and SIGTERM, or SIGHUP for SignalReopen where there is one
+	Action   SignalAction  // defaults to SignalReraise
+	ExitCode int           // used by SignalExit, defaults to 128 + the signal number
+	Timeout  time.Duration // deadline for shutting down or flushing, defaults to 5s
+}
+
+const defaultSignalTimeout = 5 * time.Second
+
+// HandleSignals flushes or shuts down the loggers when a signal arrives
+//
+// Signal handling is opt-in, importing the package leaves signals alone.
+// With SignalReraise and SignalExit a second signal during shutdown exits immediately.
+// Use SignalFlush alongside signal.NotifyContext so the application
+// still decides when to stop
+//
+//	stop := log.HandleSignals(log.SignalOptions{})
+//	defer stop()
+//
+// SignalReopen pairs with logrotate's move-and-create policy,
+// call HandleSignals once per action. Without it a moved log
+// is only noticed by a check running once a minute
+//
+//	log.HandleSignals(log.SignalOptions{Action: log.SignalReopen})
+func HandleSignals(opts SignalOptions) (stop func()) {
+	if len(opts.Signals) == 0 {
+		if opts.Action == SignalReopen {
+			opts.Signals = reopenSignals
+		} else {
+			opts.Signals = []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+		}
+	}
+	if len(opts.Signals) == 0 {
+		return func() {} // signal.Notify would relay every signal
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultSignalTimeout
+	}
+
+	sigs := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(sigs, opts.Signals...)
+
+	go noPanicRunVoid("log-signal-handler", func() {
+		for {
+			select {
+			case <-done:
+				return
+			case sig := <-sigs:
+				switch opts.Action {
+				case SignalFlush:
+					flushOnSignal(opts.Timeout)
+					continue
+				case SignalReopen:
+					if err := ReopenFiles(); err != nil {
+						fmt.Fprintf(os.Stderr, "log reopen failed: %v\n", err)
+					}
+					continue
+				}
+
+				go func() { // a second signal skips the shutdown
+					select {
+					case <-sigs:
+						os.Exit(exitCodeFor(opts.ExitCode, sig))
+					case <-done:
+					}
+				}()
+				shutdownOnSignal(opts, sigs, sig)
+				return
+			}
+		}
+	})
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(sigs)
+			close(done)
+		})
+	}
+}
+
+func flushOnSignal(timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := flushAll(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "log flush failed: %v\n", err)
+	}
+}
+
+func shutdownOnSignal(opts SignalOptions, sigs chan<- os.Signal, sig os.Signal) {
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+	if err := Shutdown(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "log shutdown failed: %v\n", err)
+	}
+	cancel()
+
+	if opts.Action == SignalReraise {
+		signal.Stop(sigs) // signal.Notify registrations of the application stay
+		if p, err := os.FindProcess(os.Getpid()); err == nil && p.Signal(sig) == nil {
+			time.Sleep(time.Second) // give the signal time to be delivered
+		}
+	}
+	os.Exit(exitCodeFor(opts.ExitCode, sig))
+}
+
+func exitCodeFor(code int, sig os.Signal) int {
+	if code != 0 {
+		return code
+	}
+	if n, ok := signalNumber(sig); ok {
+		return 128 + n
+	}
+	return 1
+}
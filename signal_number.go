@@ -0,0 +1,13 @@
+//go:build !plan9
+
+package log
+
+import (
+	"os"
+	"syscall"
+)
+
+func signalNumber(sig os.Signal) (int, bool) {
+	s, ok := sig.(syscall.Signal)
+	return int(s), ok
+}
@@ -0,0 +1,8 @@
+//go:build plan9
+
+package log
+
+import "os"
+
+// signalNumber has nothing to return, plan9 signals are notes
+func signalNumber(os.Signal) (int, bool) { return 0, false }
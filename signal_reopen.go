@@ -0,0 +1,10 @@
+//go:build !js && !wasip1 && !plan9
+
+package log
+
+import (
+	"os"
+	"syscall"
+)
+
+var reopenSignals = []os.Signal{syscall.SIGHUP}
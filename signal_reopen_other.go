@@ -0,0 +1,8 @@
+//go:build js || wasip1 || plan9
+
+package log
+
+import "os"
+
+// there is no SIGHUP, SignalReopen needs SignalOptions.Signals
+var reopenSignals []os.Signal
@@ -0,0 +1,80 @@
+//go:build unix
+
+package log_test
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/lattesec/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleSignalsFlush(t *testing.T) {
+	var flushed atomic.Int32
+	l, err := log.NewLogger().
+		WithLevel(log.INFO).
+		WithStderr(false).
+		WithStdout(false).
+		WithHandlers(&log.BaseHandler{
+			HandleFunc: func(context.Context, *log.LogMessage) error { return nil },
+			FlushFunc: func(context.Context, log.LogHandler) error {
+				flushed.Add(1)
+				return nil
+			},
+		}).
+		Build()
+	require.NoError(t, err)
+	require.NoError(t, l.Start(), "failed to start logger")
+	defer func() { require.NoError(t, l.Close()) }()
+
+	// the application keeps its own handling next to ours
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGUSR1)
+	defer cancel()
+
+	stop := log.HandleSignals(log.SignalOptions{
+		Signals: []os.Signal{syscall.SIGUSR1},
+		Action:  log.SignalFlush,
+	})
+	defer stop()
+
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGUSR1))
+
+	require.Eventually(t, func() bool { return flushed.Load() > 0 }, time.Second, time.Millisecond,
+		"expected handlers to be flushed on signal")
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("expected the application to still receive the signal")
+	}
+	require.True(t, l.IsRunning(), "expected SignalFlush to keep loggers running")
+}
+
+func TestHandleSignalsReopen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "hup.log")
+
+	h, err := log.NewFileHandler(path)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, h.Close()) }()
+
+	stop := log.HandleSignals(log.SignalOptions{
+		Signals: []os.Signal{syscall.SIGUSR2},
+		Action:  log.SignalReopen,
+	})
+	defer stop()
+
+	require.NoError(t, os.Rename(path, path+".1"))
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGUSR2))
+
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}, time.Second, time.Millisecond, "expected the log to be reopened on signal")
+}
@@ -0,0 +1,201 @@
+package log
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SlogHandler is a slog.Handler backed by a Logger,
+// records go through the logger's LogHandlers
+//
+//	slog.SetDefault(slog.New(log.NewSlogHandler(logger)))
+type SlogHandler struct {
+	logger *Logger
+	fields []Field     // top-level attrs from WithAttrs
+	groups []slogGroup // groups opened by WithGroup, outermost first
+}
+
+type slogGroup struct {
+	name   string
+	fields []Field
+}
+
+func NewSlogHandler(l *Logger) *SlogHandler {
+	return &SlogHandler{logger: l}
+}
+
+// Slog returns a *slog.Logger writing through l
+func (l *Logger) Slog() *slog.Logger { return slog.New(NewSlogHandler(l)) }
+
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return FromSlogLevel(level) >= h.logger.GetLevel()
+}
+
+func (h *SlogHandler) Handle(_ context.Context, r slog.Record) error {
+	level := FromSlogLevel(r.Level)
+
+	msg := h.logger.Log(level)
+	msg.Message = r.Message
+	msg.Timestamp = r.Time.UTC() // zero times are left out by formatters
+	if r.PC != 0 {
+		// the caller found by SendLog would point into slog
+		c := h.logger.core()
+		c.mu.RLock()
+		withCaller := c.wantsDebugInfo(level)
+		c.mu.RUnlock()
+		if withCaller {
+			msg.pc = r.PC
+			msg.caller = callerFromPC(r.PC)
+		}
+	}
+
+	// attrs of the record belong to the innermost group
+	inner := make([]Field, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		inner = appendSlogAttr(inner, a)
+		return true
+	})
+
+	for i := len(h.groups) - 1; i >= 0; i-- {
+		g := h.groups[i]
+		fields := make([]Field, 0, len(g.fields)+len(inner))
+		fields = append(fields, g.fields...)
+		fields = append(fields, inner...)
+
+		inner = nil
+		if len(fields) > 0 {
+			inner = []Field{Group(g.name, fields...)}
+		}
+	}
+
+	msg.Meta = append(msg.Meta, h.fields...)
+	msg.Meta = append(msg.Meta, inner...)
+
+	h.logger.SendLog(msg)
+	return nil
+}
+
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+
+	h2 := h.clone()
+	if n := len(h2.groups); n > 0 {
+		g := &h2.groups[n-1]
+		g.fields = appendSlogAttrs(g.fields[:len(g.fields):len(g.fields)], attrs)
+	} else {
+		h2.fields = appendSlogAttrs(h2.fields[:len(h2.fields):len(h2.fields)], attrs)
+	}
+	return h2
+}
+
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	h2 := h.clone()
+	h2.groups = append(h2.groups, slogGroup{name: name})
+	return h2
+}
+
+func (h *SlogHandler) clone() *SlogHandler {
+	return &SlogHandler{
+		logger: h.logger,
+		fields: h.fields,
+		groups: append([]slogGroup(nil), h.groups...),
+	}
+}
+
+// FromSlogLevel maps slog levels onto the closest Level
+//
+// Levels above slog.LevelError map onto PANIC and FATAL,
+// they only label the message and never panic or exit
+func FromSlogLevel(level slog.Level) Level {
+	switch {
+	case level < slog.LevelDebug:
+		return TRACE
+	case level < slog.LevelInfo:
+		return DEBUG
+	case level < slog.LevelWarn:
+		return INFO
+	case level < slog.LevelError:
+		return WARN
+	case level < slog.LevelError+4:
+		return ERROR
+	case level < slog.LevelError+8:
+		return PANIC
+	default:
+		return FATAL
+	}
+}
+
+// ToSlogLevel maps a Level onto slog levels
+func ToSlogLevel(level Level) slog.Level {
+	switch level {
+	case TRACE:
+		return slog.LevelDebug - 4
+	case DEBUG:
+		return slog.LevelDebug
+	case INFO:
+		return slog.LevelInfo
+	case WARN:
+		return slog.LevelWarn
+	case ERROR:
+		return slog.LevelError
+	case PANIC:
+		return slog.LevelError + 4
+	default:
+		return slog.LevelError + 8
+	}
+}
+
+func appendSlogAttrs(fs []Field, attrs []slog.Attr) []Field {
+	for _, a := range attrs {
+		fs = appendSlogAttr(fs, a)
+	}
+	return fs
+}
+
+// follows the slog.Handler rules for empty attrs and groups
+func appendSlogAttr(fs []Field, a slog.Attr) []Field {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return fs
+	}
+
+	if a.Value.Kind() != slog.KindGroup {
+		return append(fs, Field{K: a.Key, V: fromSlogValue(a.Value)})
+	}
+
+	attrs := a.Value.Group()
+	if len(attrs) == 0 {
+		return fs
+	}
+	if a.Key == "" {
+		return appendSlogAttrs(fs, attrs)
+	}
+	return append(fs, Group(a.Key, appendSlogAttrs(nil, attrs)...))
+}
+
+func fromSlogValue(v slog.Value) Value {
+	switch v.Kind() {
+	case slog.KindString:
+		return StringValue(v.String())
+	case slog.KindInt64:
+		return Int64Value(v.Int64())
+	case slog.KindUint64:
+		return Uint64Value(v.Uint64())
+	case slog.KindFloat64:
+		return Float64Value(v.Float64())
+	case slog.KindBool:
+		return BoolValue(v.Bool())
+	case slog.KindDuration:
+		return DurationValue(v.Duration())
+	case slog.KindTime:
+		return TimeValue(v.Time())
+	default:
+		return AnyValue(v.Any())
+	}
+}
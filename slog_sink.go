@@ -0,0 +1,85 @@
+package log
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SlogSink is a LogHandler writing to a slog.Handler
+//
+// Messages are queued like any other BaseHandler,
+// so a slow slog.Handler does not block Logger.SendLog
+type SlogSink struct {
+	BaseHandler
+	handler slog.Handler
+}
+
+// NewSlogSink wraps h as a LogHandler
+//
+// The logger name is added as a "logger" attr
+func NewSlogSink(h slog.Handler) *SlogSink {
+	s := &SlogSink{handler: h}
+
+	s.BaseHandler = BaseHandler{
+		HandleFunc: func(ctx context.Context, msg *LogMessage) error {
+			level := ToSlogLevel(msg.Level)
+			if !s.handler.Enabled(ctx, level) {
+				return nil
+			}
+			return s.handler.Handle(ctx, msg.SlogRecord())
+		},
+	}
+
+	return s
+}
+
+func (s *SlogSink) Handler() slog.Handler { return s.handler }
+
+// SlogRecord converts the message into a slog.Record
+func (lm *LogMessage) SlogRecord() slog.Record {
+	r := slog.NewRecord(lm.Timestamp, ToSlogLevel(lm.Level), lm.Message, lm.pc)
+	if lm.loggerName != "" {
+		r.AddAttrs(slog.String("logger", lm.loggerName))
+	}
+	for _, f := range lm.Meta {
+		r.AddAttrs(f.SlogAttr())
+	}
+	return r
+}
+
+func (f Field) SlogAttr() slog.Attr {
+	return slog.Attr{Key: f.K, Value: f.V.SlogValue()}
+}
+
+func (v Value) SlogValue() slog.Value {
+	switch v.kind {
+	case KindString:
+		return slog.StringValue(v.str)
+	case KindInt64:
+		return slog.Int64Value(v.Int64())
+	case KindUint64:
+		return slog.Uint64Value(v.num)
+	case KindFloat64:
+		return slog.Float64Value(v.Float64())
+	case KindBool:
+		return slog.BoolValue(v.Bool())
+	case KindDuration:
+		return slog.DurationValue(v.Duration())
+	case KindTime:
+		return slog.TimeValue(v.Time())
+	case KindGroup:
+		fs := v.Group()
+		attrs := make([]slog.Attr, 0, len(fs))
+		for _, f := range fs {
+			attrs = append(attrs, f.SlogAttr())
+		}
+		return slog.GroupValue(attrs...)
+	case KindError:
+		if v.any == nil {
+			return slog.StringValue("<nil>")
+		}
+		return slog.AnyValue(v.any)
+	default:
+		return slog.AnyValue(v.any)
+	}
+}
@@ -0,0 +1,121 @@
+package log_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"testing/slogtest"
+
+	"github.com/lattesec/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlogHandler(t *testing.T) {
+	var buf bytes.Buffer
+
+	l, err := log.NewLogger().
+		WithLevel(log.INFO).
+		WithStderr(false).
+		WithStdout(false).
+		WithHandlers(log.NewWriterHandler(&buf)).
+		Name("slog").
+		Build()
+	require.NoError(t, err)
+	require.NoError(t, l.Start(), "failed to start logger")
+
+	sl := l.Slog().With("svc", "api").WithGroup("req")
+	sl.Debug("filtered")
+	sl.Info("handled", "status", 200, slog.Group("user", "id", 7), slog.Group("empty"))
+	sl.With("a", 1).WithGroup("inner").Warn("nested")
+	sl.WithGroup("unused").Error("no empty groups")
+
+	require.NoError(t, l.Close())
+
+	got := buf.String()
+	assert.NotContains(t, got, "filtered")
+	assert.Contains(t, got, "[INFO] slog: handled {svc=api, req={status=200, user={id=7}}}")
+	assert.Contains(t, got, "[WARN] slog: nested {svc=api, req={a=1}}")
+	assert.Contains(t, got, "[ERROR] slog: no empty groups {svc=api}")
+}
+
+func TestSlogHandlerConformance(t *testing.T) {
+	var results []map[string]any
+
+	l, err := log.NewLogger().
+		WithLevel(log.INFO).
+		WithStderr(false).
+		WithStdout(false).
+		WithHandlers(&recordingHandler{results: &results}).
+		Build()
+	require.NoError(t, err)
+	require.NoError(t, l.Start(), "failed to start logger")
+
+	require.NoError(t, slogtest.TestHandler(log.NewSlogHandler(l), func() []map[string]any {
+		require.NoError(t, l.Close())
+		return results
+	}))
+}
+
+// records messages as maps in the shape slogtest expects
+type recordingHandler struct {
+	log.BaseHandler
+	results *[]map[string]any
+}
+
+func (r *recordingHandler) Start() error {
+	r.HandleFunc = func(_ context.Context, msg *log.LogMessage) error {
+		m := map[string]any{
+			slog.LevelKey:   log.ToSlogLevel(msg.Level),
+			slog.MessageKey: msg.Message,
+		}
+		if !msg.Timestamp.IsZero() {
+			m[slog.TimeKey] = msg.Timestamp
+		}
+		addFields(m, msg.Meta)
+		*r.results = append(*r.results, m)
+		return nil
+	}
+	return r.BaseHandler.Start()
+}
+
+func addFields(m map[string]any, fs []log.Field) {
+	for _, f := range fs {
+		if f.V.Kind() == log.KindGroup {
+			sub := map[string]any{}
+			addFields(sub, f.V.Group())
+			m[f.K] = sub
+			continue
+		}
+		m[f.K] = f.V.Any()
+	}
+}
+
+func TestSlogSink(t *testing.T) {
+	var buf bytes.Buffer
+
+	sink := log.NewSlogSink(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	l, err := log.NewLogger().
+		WithLevel(log.DEBUG).
+		WithStderr(false).
+		WithStdout(false).
+		WithHandlers(sink).
+		Name("sink").
+		Build()
+	require.NoError(t, err)
+	require.NoError(t, l.Start(), "failed to start logger")
+
+	l.Warn().Msg("via slog").WithFields(log.Int("n", 3), log.Group("g", log.Bool("b", true))).Send()
+
+	require.NoError(t, l.Close())
+
+	var got map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &got), "expected valid JSON, got %q", buf.String())
+	assert.Equal(t, "WARN", got["level"])
+	assert.Equal(t, "via slog", got["msg"])
+	assert.Equal(t, "sink", got["logger"])
+	assert.Equal(t, float64(3), got["n"])
+	assert.Equal(t, map[string]any{"b": true}, got["g"])
+}